@@ -0,0 +1,104 @@
+// Package auth authenticates API requests with API keys or JWT bearer tokens
+// and authorizes them by role.
+package auth
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Role is the set of permissions granted to a caller.
+type Role string
+
+const (
+	// RoleReader reads books.
+	RoleReader Role = "reader"
+	// RoleEditor reads, creates, updates and deletes books.
+	RoleEditor Role = "editor"
+	// RoleAdmin can do everything, including cache administration.
+	RoleAdmin Role = "admin"
+)
+
+// Permission is required by a route.
+type Permission string
+
+// Permissions checked by the API routes.
+const (
+	PermReadBooks   Permission = "books:read"
+	PermWriteBooks  Permission = "books:write"
+	PermDeleteBooks Permission = "books:delete"
+	PermManageCache Permission = "cache:manage"
+)
+
+// roles lists the roles from the least to the most privileged with their
+// permissions.
+var roles = []struct {
+	role        Role
+	permissions []Permission
+}{
+	{RoleReader, []Permission{PermReadBooks}},
+	{RoleEditor, []Permission{PermReadBooks, PermWriteBooks, PermDeleteBooks}},
+	{RoleAdmin, []Permission{PermReadBooks, PermWriteBooks, PermDeleteBooks, PermManageCache}},
+}
+
+// ParseRole returns the role called name.
+func ParseRole(name string) (Role, error) {
+	if rank(Role(name)) < 0 {
+		return "", errors.Errorf("unknown role %q (expected reader, editor or admin)", name)
+	}
+	return Role(name), nil
+}
+
+// Can reports whether the role grants p.
+func (r Role) Can(p Permission) bool {
+	i := rank(r)
+	if i < 0 {
+		return false
+	}
+	for _, granted := range roles[i].permissions {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// rank returns the position of r in roles, or -1 for unknown roles.
+func rank(r Role) int {
+	for i, known := range roles {
+		if known.role == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Authentication methods of a Principal.
+const (
+	MethodAPIKey    = "apikey"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject is the API key prefix or the subject of the JWT.
+	Subject string
+	Role    Role
+	Method  string
+}
+
+// principalKey is the context key of the principal.
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
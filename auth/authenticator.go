@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// apiKeyHeader carries an API key for clients that cannot send an
+// Authorization header.
+const apiKeyHeader = "X-API-Key"
+
+// defaultLeeway is the clock skew tolerated on JWT time claims unless
+// auth.jwt.leeway is set.
+const defaultLeeway = time.Minute
+
+// ErrInvalidCredentials is returned for unknown, revoked or malformed API
+// keys and for tokens that fail verification.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator identifies the caller of a request by its API key or JWT.
+type Authenticator struct {
+	keys KeyRepository
+	jwt  *jwtVerifier
+	// anonymous is the role of requests without credentials, or "" to
+	// reject them on routes that require a permission.
+	anonymous Role
+}
+
+// NewAuthenticator returns an Authenticator that looks up API keys in keys.
+// JWT bearer tokens are accepted when auth.jwt.jwks_file is set, or when
+// auth.jwt.discovery is set to verify them with the keys of the OpenID
+// Connect provider auth.jwt.issuer. Tokens must have been issued by
+// auth.jwt.issuer for auth.jwt.audience with the auth.jwt.scopes when those
+// are set. Requests without credentials get auth.anonymous_role, if any.
+func NewAuthenticator(keys KeyRepository) (*Authenticator, error) {
+	a := &Authenticator{keys: keys}
+
+	if name := viper.GetString("auth.anonymous_role"); name != "" {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid auth.anonymous_role")
+		}
+		a.anonymous = role
+	}
+
+	var source keySource
+	issuer := viper.GetString("auth.jwt.issuer")
+	path := viper.GetString("auth.jwt.jwks_file")
+	switch {
+	case viper.GetBool("auth.jwt.discovery"):
+		if issuer == "" {
+			return nil, errors.New("auth.jwt.discovery requires auth.jwt.issuer")
+		}
+		if path != "" {
+			return nil, errors.New("set either auth.jwt.discovery or auth.jwt.jwks_file")
+		}
+		refresh := defaultKeyRefresh
+		if viper.IsSet("auth.jwt.jwks_refresh") {
+			refresh = viper.GetDuration("auth.jwt.jwks_refresh")
+		}
+		source = newOIDCKeys(issuer, refresh)
+	case path != "":
+		jwks, err := loadJWKS(path)
+		if err != nil {
+			return nil, err
+		}
+		source = jwks
+	}
+
+	if source != nil {
+		roleMap, err := parseRoleMap(listSetting("auth.jwt.roles"))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid auth.jwt.roles")
+		}
+		roleClaim := viper.GetString("auth.jwt.role_claim")
+		if roleClaim == "" {
+			roleClaim = defaultRoleClaim
+		}
+		leeway := defaultLeeway
+		if viper.IsSet("auth.jwt.leeway") {
+			leeway = viper.GetDuration("auth.jwt.leeway")
+		}
+
+		a.jwt = &jwtVerifier{
+			keys:      source,
+			issuer:    issuer,
+			audience:  viper.GetString("auth.jwt.audience"),
+			scopes:    listSetting("auth.jwt.scopes"),
+			roleClaim: roleClaim,
+			roles:     roleMap,
+			leeway:    leeway,
+			now:       time.Now,
+		}
+	}
+
+	return a, nil
+}
+
+// listSetting returns the values of a list setting. Environment variables
+// are comma or space separated lists.
+func listSetting(key string) []string {
+	var values []string
+	for _, value := range viper.GetStringSlice(key) {
+		values = append(values, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})...)
+	}
+	return values
+}
+
+// Authenticate returns the principal of r. ok is false when r has no
+// credentials and anonymous requests have no role. Credentials that cannot
+// be verified return an error wrapping ErrInvalidCredentials, or
+// ErrKeysUnavailable when the identity provider cannot be reached.
+func (a *Authenticator) Authenticate(ctx context.Context, r *http.Request) (_ Principal, ok bool, err error) {
+	token := r.Header.Get(apiKeyHeader)
+	if token == "" {
+		scheme, credentials, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(credentials)
+		}
+	}
+
+	if token == "" {
+		if a.anonymous == "" {
+			return Principal{}, false, nil
+		}
+		return Principal{Role: a.anonymous, Method: MethodAnonymous}, true, nil
+	}
+
+	if isAPIKey(token) {
+		principal, err := a.apiKey(ctx, token)
+		return principal, err == nil, err
+	}
+
+	if a.jwt == nil {
+		return Principal{}, false, errors.Wrap(ErrInvalidCredentials, "bearer tokens are not configured")
+	}
+	principal, err := a.jwt.verify(ctx, token)
+	if errors.Is(err, ErrKeysUnavailable) {
+		return Principal{}, false, err
+	}
+	if err != nil {
+		return Principal{}, false, errors.Wrap(ErrInvalidCredentials, err.Error())
+	}
+	return principal, true, nil
+}
+
+// apiKey returns the principal of an API key.
+func (a *Authenticator) apiKey(ctx context.Context, secret string) (Principal, error) {
+	prefix, ok := keyPrefix(secret)
+	if !ok || a.keys == nil {
+		return Principal{}, errors.Wrap(ErrInvalidCredentials, "malformed API key")
+	}
+
+	key, err := a.keys.GetByPrefix(ctx, prefix)
+	if errors.Is(err, ErrKeyNotFound) {
+		return Principal{}, errors.Wrap(ErrInvalidCredentials, "unknown API key")
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if !key.Matches(secret) {
+		return Principal{}, errors.Wrap(ErrInvalidCredentials, "unknown API key")
+	}
+	if key.Revoked() {
+		return Principal{}, errors.Wrap(ErrInvalidCredentials, "revoked API key")
+	}
+
+	return Principal{Subject: key.Prefix, Role: key.Role, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,210 @@
+// Package authtest contains a stand-in OpenID Connect identity provider for
+// tests of token authentication.
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// IdP serves an OpenID Connect discovery document and a JWKS, and issues
+// ES256 tokens signed with its current key.
+type IdP struct {
+	server *httptest.Server
+
+	mu sync.Mutex
+	// keys are published in the JWKS; the last one signs tokens.
+	keys   []jose.JSONWebKey
+	nextID int
+
+	// stalled, while set, holds JWKS responses until it is closed.
+	stalled chan struct{}
+
+	jwksRequests atomic.Int64
+	// failing makes the JWKS endpoint answer 503.
+	failing atomic.Bool
+	// trailingSlash makes the issuer end in a slash.
+	trailingSlash atomic.Bool
+}
+
+// NewIdP starts an identity provider with one signing key. Close it when
+// done.
+func NewIdP() *IdP {
+	p := &IdP{}
+	p.addKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":   p.Issuer(),
+			"jwks_uri": p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksRequests.Add(1)
+
+		p.mu.Lock()
+		stalled := p.stalled
+		p.mu.Unlock()
+		if stalled != nil {
+			select {
+			case <-stalled:
+			case <-r.Context().Done():
+			}
+		}
+
+		if p.failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, p.JWKS())
+	})
+	p.server = httptest.NewServer(mux)
+
+	return p
+}
+
+// Close shuts the identity provider down.
+func (p *IdP) Close() {
+	p.server.Close()
+}
+
+// Issuer returns the issuer URL, which is also the base of the discovery URL.
+func (p *IdP) Issuer() string {
+	if p.trailingSlash.Load() {
+		return p.server.URL + "/"
+	}
+	return p.server.URL
+}
+
+// SetTrailingSlash makes the issuer end in a slash, as those of some
+// providers such as Auth0 do.
+func (p *IdP) SetTrailingSlash(trailing bool) {
+	p.trailingSlash.Store(trailing)
+}
+
+// JWKS returns the public keys currently published.
+func (p *IdP) JWKS() jose.JSONWebKeySet {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	set := jose.JSONWebKeySet{}
+	for _, key := range p.keys {
+		set.Keys = append(set.Keys, key.Public())
+	}
+	return set
+}
+
+// JWKSRequests returns how many times the JWKS was requested.
+func (p *IdP) JWKSRequests() int {
+	return int(p.jwksRequests.Load())
+}
+
+// SetFailing makes the JWKS endpoint fail until it is called with false.
+func (p *IdP) SetFailing(failing bool) {
+	p.failing.Store(failing)
+}
+
+// Stall holds JWKS responses until the returned function is called, as a
+// slow provider would.
+func (p *IdP) Stall() (release func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stalled := make(chan struct{})
+	p.stalled = stalled
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			if p.stalled == stalled {
+				p.stalled = nil
+			}
+			p.mu.Unlock()
+			close(stalled)
+		})
+	}
+}
+
+// Rotate signs tokens with a new key from now on. The previous keys stay
+// published unless retire is set.
+func (p *IdP) Rotate(retire bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if retire {
+		p.keys = nil
+	}
+	p.addKeyLocked()
+}
+
+// Token returns a token signed with the current key. iss, iat and exp (one
+// hour from now) are set unless claims has them.
+func (p *IdP) Token(claims map[string]interface{}) string {
+	p.mu.Lock()
+	key := p.keys[len(p.keys)-1]
+	p.mu.Unlock()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", key.KeyID),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	now := time.Now()
+	all := map[string]interface{}{
+		"iss": p.Issuer(),
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	for name, value := range claims {
+		all[name] = value
+	}
+
+	token, err := jwt.Signed(signer).Claims(all).Serialize()
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+// addKey generates a signing key.
+func (p *IdP) addKey() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addKeyLocked()
+}
+
+// addKeyLocked generates a signing key; p.mu must be held.
+func (p *IdP) addKeyLocked() {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	p.nextID++
+	p.keys = append(p.keys, jose.JSONWebKey{
+		Key:       private,
+		KeyID:     "key-" + strconv.Itoa(p.nextID),
+		Algorithm: string(jose.ES256),
+		Use:       "sig",
+	})
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/pkg/errors"
+)
+
+// signatureAlgorithms are the accepted JWT algorithms. Tokens are verified
+// with public keys, so HMAC algorithms are not among them.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// defaultRoleClaim holds the role of the caller unless auth.jwt.role_claim
+// names another claim.
+const defaultRoleClaim = "role"
+
+// ErrKeysUnavailable is returned when the keys verifying a token cannot be
+// fetched from the identity provider.
+var ErrKeysUnavailable = errors.New("identity provider keys are unavailable")
+
+// keySource returns the verification key with an ID.
+type keySource interface {
+	key(ctx context.Context, kid string) (*jose.JSONWebKey, error)
+}
+
+// jwtVerifier verifies JWT bearer tokens and maps them to a principal.
+type jwtVerifier struct {
+	keys     keySource
+	issuer   string
+	audience string
+	// scopes must all be granted by the scope or scp claim.
+	scopes []string
+	// roleClaim is a claim name, or a dotted path into nested claims such as
+	// realm_access.roles.
+	roleClaim string
+	// roles maps role claim values, e.g. groups, to roles. When empty the
+	// values are role names.
+	roles  map[string]Role
+	leeway time.Duration
+	now    func() time.Time
+}
+
+// staticKeys is a key set read from a file.
+type staticKeys struct {
+	set *jose.JSONWebKeySet
+}
+
+// loadJWKS reads a JSON Web Key Set file.
+func loadJWKS(path string) (*staticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, errors.Wrapf(err, "invalid JWKS file %s", path)
+	}
+	if len(keys.Keys) == 0 {
+		return nil, errors.Errorf("JWKS file %s has no keys", path)
+	}
+	return &staticKeys{set: &keys}, nil
+}
+
+// key returns the key with the ID kid.
+func (s *staticKeys) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	key, err := findKey(s.set, kid)
+	return key, errors.WithStack(err)
+}
+
+// findKey returns the key with the ID kid. Tokens without a key ID are
+// accepted when the set has a single key.
+func findKey(set *jose.JSONWebKeySet, kid string) (*jose.JSONWebKey, error) {
+	if kid == "" {
+		if len(set.Keys) == 1 {
+			return &set.Keys[0], nil
+		}
+		return nil, errors.New("token has no key ID")
+	}
+	keys := set.Key(kid)
+	if len(keys) == 0 {
+		return nil, errors.Errorf("unknown key ID %q", kid)
+	}
+	return &keys[0], nil
+}
+
+// verify checks the signature, expiry, issuer, audience and scopes of token
+// and returns the principal it identifies. Errors wrapping
+// ErrKeysUnavailable are not the caller's fault.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (Principal, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Principal{}, errors.Wrap(err, "malformed token")
+	}
+
+	key, err := v.keys.key(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	var claims jwt.Claims
+	var custom map[string]interface{}
+	if err := parsed.Claims(key, &claims, &custom); err != nil {
+		return Principal{}, errors.Wrap(err, "invalid signature")
+	}
+
+	if claims.Expiry == nil {
+		return Principal{}, errors.New("token has no expiry")
+	}
+	expected := jwt.Expected{Issuer: v.issuer, Time: v.now()}
+	if v.audience != "" {
+		expected.AnyAudience = jwt.Audience{v.audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, v.leeway); err != nil {
+		return Principal{}, errors.WithStack(err)
+	}
+
+	if len(v.scopes) > 0 {
+		granted := claimValues(custom["scope"])
+		granted = append(granted, claimValues(custom["scp"])...)
+		for _, scope := range v.scopes {
+			if !contains(granted, scope) {
+				return Principal{}, errors.Errorf("token lacks the %s scope", scope)
+			}
+		}
+	}
+
+	role, ok := v.role(claimValues(lookupClaim(custom, v.roleClaim)))
+	if !ok {
+		return Principal{}, errors.Errorf("token has no known role in the %s claim", v.roleClaim)
+	}
+
+	return Principal{Subject: claims.Subject, Role: role, Method: MethodJWT}, nil
+}
+
+// role returns the most privileged role granted by the role claim values.
+func (v *jwtVerifier) role(values []string) (Role, bool) {
+	best := -1
+	for _, value := range values {
+		role := Role(value)
+		if len(v.roles) > 0 {
+			role = v.roles[value]
+		}
+		if i := rank(role); i > best {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return roles[best].role, true
+}
+
+// parseRoleMap parses "value=role" mappings of role claim values to roles.
+func parseRoleMap(mappings []string) (map[string]Role, error) {
+	roleMap := make(map[string]Role, len(mappings))
+	for _, mapping := range mappings {
+		value, name, ok := strings.Cut(mapping, "=")
+		if !ok || value == "" {
+			return nil, errors.Errorf("invalid role mapping %q (expected value=role)", mapping)
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid role mapping %q", mapping)
+		}
+		roleMap[value] = role
+	}
+	return roleMap, nil
+}
+
+// lookupClaim returns the claim at a dotted path, or nil.
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// claimValues returns the values of a claim that is either a space
+// separated string or an array of strings.
+func claimValues(claim interface{}) []string {
+	var values []string
+	switch claim := claim.(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []interface{}:
+		for _, value := range claim {
+			if value, ok := value.(string); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware authenticates every request and stores its principal in the
+// request context for Require. Requests with invalid credentials are
+// rejected with 401, and bearer tokens that cannot be verified because the
+// identity provider is down with 503; requests without credentials go on
+// unauthenticated.
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			principal, ok, err := a.Authenticate(ctx, c.Request())
+			if errors.Is(err, ErrInvalidCredentials) {
+				slog.InfoContext(ctx, "authentication failed", "reason", err.Error())
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+			}
+			if errors.Is(err, ErrKeysUnavailable) {
+				slog.WarnContext(ctx, "failed to verify bearer token", "error", err.Error())
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "identity provider unavailable")
+			}
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return next(c)
+			}
+
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.String("enduser.id", principal.Subject),
+				attribute.String("enduser.role", string(principal.Role)),
+			)
+			c.SetRequest(c.Request().WithContext(WithPrincipal(ctx, principal)))
+			return next(c)
+		}
+	}
+}
+
+// Require rejects requests whose principal lacks p: with 401 when the
+// request has no credentials and 403 otherwise.
+func Require(p Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := PrincipalFrom(c.Request().Context())
+			if !ok || (principal.Method == MethodAnonymous && !principal.Role.Can(p)) {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+			if !principal.Role.Can(p) {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+			}
+			return next(c)
+		}
+	}
+}
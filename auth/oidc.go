@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/pkg/errors"
+)
+
+// discoveryPath is appended to the issuer URL to get the OpenID Connect
+// provider metadata.
+const discoveryPath = "/.well-known/openid-configuration"
+
+const (
+	// defaultKeyRefresh is how long fetched keys are used before they are
+	// fetched again, unless auth.jwt.jwks_refresh is set.
+	defaultKeyRefresh = time.Hour
+
+	// minKeyRefresh limits how often tokens signed with unknown keys make
+	// the keys be fetched again, so that forged key IDs cannot flood the
+	// identity provider.
+	minKeyRefresh = 30 * time.Second
+)
+
+// oidcKeys is the key set of an OpenID Connect provider. The JWKS URL is
+// discovered from the issuer on first use. Keys are cached and fetched again
+// once they are older than refresh, or when a token names an unknown key
+// because the provider rotated its keys. Fetches run one at a time without
+// holding up tokens whose key is cached, and the cached keys are kept while
+// the provider is unreachable.
+type oidcKeys struct {
+	issuer  string
+	client  *http.Client
+	refresh time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	jwksURL   string
+	set       *jose.JSONWebKeySet
+	fetchedAt time.Time
+	// fetching is closed when the fetch in progress, if any, is done.
+	fetching chan struct{}
+	// attemptedAt and lastErr rate limit fetches while the provider fails.
+	attemptedAt time.Time
+	lastErr     error
+}
+
+// newOIDCKeys returns the key set of the provider issuer.
+func newOIDCKeys(issuer string, refresh time.Duration) *oidcKeys {
+	return &oidcKeys{
+		issuer:  issuer,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+// key returns the key with the ID kid. A cached key is returned right away,
+// fetching the keys in the background when they are stale. Otherwise key
+// waits for the keys to be fetched.
+func (o *oidcKeys) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	o.mu.Lock()
+	now := o.now()
+	if o.set != nil {
+		if key, err := findKey(o.set, kid); err == nil {
+			if now.Sub(o.fetchedAt) >= o.refresh {
+				o.startFetch(ctx, now)
+			}
+			o.mu.Unlock()
+			return key, nil
+		}
+	}
+	done := o.startFetch(ctx, now)
+	o.mu.Unlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ErrKeysUnavailable, ctx.Err().Error())
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.set == nil {
+		return nil, errors.Wrap(ErrKeysUnavailable, o.lastErr.Error())
+	}
+	key, err := findKey(o.set, kid)
+	return key, errors.WithStack(err)
+}
+
+// startFetch fetches the keys in the background unless a fetch is in
+// progress or the last one started less than minKeyRefresh ago. It returns
+// the channel closed when the fetch in progress is done, or nil. o.mu must be
+// held.
+func (o *oidcKeys) startFetch(ctx context.Context, now time.Time) <-chan struct{} {
+	if o.fetching != nil {
+		return o.fetching
+	}
+	if now.Sub(o.attemptedAt) < minKeyRefresh {
+		return nil
+	}
+
+	o.attemptedAt = now
+	done := make(chan struct{})
+	o.fetching = done
+	jwksURL := o.jwksURL
+
+	go func() {
+		defer close(done)
+
+		// The keys serve every request, so do not give up with this one
+		jwksURL, set, err := o.fetch(context.WithoutCancel(ctx), jwksURL)
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		o.fetching = nil
+		o.lastErr = err
+		if err != nil {
+			if o.set != nil {
+				slog.WarnContext(ctx, "failed to refresh identity provider keys, using the cached keys", "issuer", o.issuer, "error", err.Error())
+			}
+			return
+		}
+		o.jwksURL = jwksURL
+		o.set = set
+		o.fetchedAt = now
+	}()
+
+	return done
+}
+
+// fetch discovers the JWKS URL if it is empty and returns it with the
+// signing keys it serves.
+func (o *oidcKeys) fetch(ctx context.Context, jwksURL string) (string, *jose.JSONWebKeySet, error) {
+	if jwksURL == "" {
+		var metadata struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		url := strings.TrimSuffix(o.issuer, "/") + discoveryPath
+		if err := o.getJSON(ctx, url, &metadata); err != nil {
+			return "", nil, errors.Wrap(err, "failed to discover the identity provider")
+		}
+		// Required by OpenID Connect Discovery, so that a provider cannot
+		// speak for another issuer
+		if metadata.Issuer != o.issuer {
+			return "", nil, errors.Errorf("discovery document of %s is for issuer %s", o.issuer, metadata.Issuer)
+		}
+		if metadata.JWKSURI == "" {
+			return "", nil, errors.Errorf("discovery document of %s has no jwks_uri", o.issuer)
+		}
+		jwksURL = metadata.JWKSURI
+	}
+
+	var set jose.JSONWebKeySet
+	if err := o.getJSON(ctx, jwksURL, &set); err != nil {
+		return "", nil, errors.Wrap(err, "failed to fetch the identity provider keys")
+	}
+
+	signing := &jose.JSONWebKeySet{}
+	for _, key := range set.Keys {
+		if key.Use == "" || key.Use == "sig" {
+			signing.Keys = append(signing.Keys, key)
+		}
+	}
+	if len(signing.Keys) == 0 {
+		return "", nil, errors.Errorf("%s has no signing keys", jwksURL)
+	}
+
+	return jwksURL, signing, nil
+}
+
+// getJSON decodes the JSON document at url into v.
+func (o *oidcKeys) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s: %s", url, res.Status)
+	}
+	return errors.Wrapf(json.NewDecoder(res.Body).Decode(v), "GET %s", url)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/books/auth/authtest"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_OIDC(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("OpenID Connect bearer tokens", t, func() {
+		idp := authtest.NewIdP()
+		defer idp.Close()
+
+		settings := map[string]interface{}{
+			"auth.jwt.discovery":  true,
+			"auth.jwt.issuer":     idp.Issuer(),
+			"auth.jwt.audience":   "books",
+			"auth.jwt.scopes":     []string{"books"},
+			"auth.jwt.role_claim": "groups",
+			"auth.jwt.roles":      []string{"staff=reader", "book-editors=editor"},
+		}
+		for key, value := range settings {
+			viper.Set(key, value)
+		}
+		defer func() {
+			for key := range settings {
+				viper.Set(key, nil)
+			}
+		}()
+
+		a, err := NewAuthenticator(keyMap{})
+		So(err, ShouldBeNil)
+
+		// Time of the key cache, advanced by the tests
+		clock := time.Now()
+		keys := a.jwt.keys.(*oidcKeys)
+		keys.now = func() time.Time { return clock }
+
+		authenticate := func(token string) (Principal, error) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			principal, _, err := a.Authenticate(ctx, r)
+			return principal, err
+		}
+		claims := func(overrides map[string]interface{}) map[string]interface{} {
+			all := map[string]interface{}{
+				"sub":    "alice",
+				"aud":    "books",
+				"scope":  "openid books",
+				"groups": []string{"staff", "book-editors"},
+			}
+			for name, value := range overrides {
+				all[name] = value
+			}
+			return all
+		}
+
+		Convey("Map the groups of a valid token to the most privileged role", func() {
+			principal, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+			So(principal, ShouldResemble, Principal{Subject: "alice", Role: RoleEditor, Method: MethodJWT})
+
+			principal, err = authenticate(idp.Token(claims(map[string]interface{}{"groups": []string{"staff"}})))
+			So(err, ShouldBeNil)
+			So(principal.Role, ShouldEqual, RoleReader)
+		})
+
+		Convey("Reject tokens for another audience, without the scopes or without a mapped group", func() {
+			_, err := authenticate(idp.Token(claims(map[string]interface{}{"aud": "other"})))
+			So(err, ShouldWrap, ErrInvalidCredentials)
+
+			_, err = authenticate(idp.Token(claims(map[string]interface{}{"scope": "openid"})))
+			So(err, ShouldWrap, ErrInvalidCredentials)
+
+			_, err = authenticate(idp.Token(claims(map[string]interface{}{"groups": []string{"admin"}})))
+			So(err, ShouldWrap, ErrInvalidCredentials)
+		})
+
+		Convey("Cache the keys until they are stale", func() {
+			for i := 0; i < 3; i++ {
+				_, err := authenticate(idp.Token(claims(nil)))
+				So(err, ShouldBeNil)
+			}
+			So(idp.JWKSRequests(), ShouldEqual, 1)
+
+			clock = clock.Add(defaultKeyRefresh)
+			_, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+			waitForFetch(keys)
+			So(idp.JWKSRequests(), ShouldEqual, 2)
+		})
+
+		Convey("Serve the cached keys while they are refreshed", func() {
+			_, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+
+			release := idp.Stall()
+			defer release()
+			clock = clock.Add(defaultKeyRefresh)
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := authenticate(idp.Token(claims(nil)))
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				So(err, ShouldBeNil)
+			case <-time.After(5 * time.Second):
+				So("the token waited for the refresh", ShouldBeEmpty)
+			}
+
+			release()
+			waitForFetch(keys)
+			So(idp.JWKSRequests(), ShouldEqual, 2)
+		})
+
+		Convey("Follow key rotations", func() {
+			oldToken := idp.Token(claims(nil))
+			_, err := authenticate(oldToken)
+			So(err, ShouldBeNil)
+
+			idp.Rotate(true)
+			clock = clock.Add(minKeyRefresh)
+			_, err = authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+			So(idp.JWKSRequests(), ShouldEqual, 2)
+
+			_, err = authenticate(oldToken)
+			So(err, ShouldWrap, ErrInvalidCredentials)
+		})
+
+		Convey("Limit how often unknown keys are fetched", func() {
+			_, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+
+			idp.Rotate(false)
+			for i := 0; i < 3; i++ {
+				_, err = authenticate(idp.Token(claims(nil)))
+				So(err, ShouldWrap, ErrInvalidCredentials)
+			}
+			So(idp.JWKSRequests(), ShouldEqual, 1)
+		})
+
+		Convey("Keep the cached keys while the provider is down", func() {
+			_, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+
+			idp.SetFailing(true)
+			clock = clock.Add(defaultKeyRefresh)
+			_, err = authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+			waitForFetch(keys)
+			So(idp.JWKSRequests(), ShouldEqual, 2)
+
+			_, err = authenticate(idp.Token(claims(nil)))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Answer 503 while no keys could be fetched", func() {
+			idp.SetFailing(true)
+
+			_, err := authenticate(idp.Token(claims(nil)))
+			So(err, ShouldWrap, ErrKeysUnavailable)
+
+			e := newTestEcho(a)
+			r := httptest.NewRequest(http.MethodGet, "/books", nil)
+			r.Header.Set("Authorization", "Bearer "+idp.Token(claims(nil)))
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("Keep an issuer that ends in a slash as it is", func() {
+			idp.SetTrailingSlash(true)
+			viper.Set("auth.jwt.issuer", idp.Issuer())
+			a, err := NewAuthenticator(keyMap{})
+			So(err, ShouldBeNil)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+idp.Token(claims(nil)))
+			principal, _, err := a.Authenticate(ctx, r)
+			So(err, ShouldBeNil)
+			So(principal.Subject, ShouldEqual, "alice")
+		})
+
+		Convey("Require an issuer for discovery", func() {
+			viper.Set("auth.jwt.issuer", "")
+			_, err := NewAuthenticator(keyMap{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// waitForFetch waits until the keys are no longer being fetched.
+func waitForFetch(o *oidcKeys) {
+	o.mu.Lock()
+	done := o.fetching
+	o.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
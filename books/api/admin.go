@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// AdminController handles cache administration requests.
+type AdminController struct {
+	service *books.BookService
+	cache   *cache.Cache
+}
+
+// newAdminController returns a new AdminController.
+func newAdminController(service *books.BookService, c *cache.Cache) *AdminController {
+	return &AdminController{service: service, cache: c}
+}
+
+// Routes sets up the routes for the admin controller. They are restricted to
+// the admin role.
+func (c *AdminController) Routes(g *echo.Group) {
+	api := g.Group("/admin/cache", ErrorHandler, auth.Require(auth.PermManageCache))
+
+	api.GET("/families", c.Families)
+	api.GET("/stats", c.Stats)
+	api.DELETE("/books/:id", c.EvictBook)
+	api.DELETE("/authors/:author", c.EvictAuthor)
+	api.DELETE("/keys", c.EvictPrefix)
+}
+
+// CacheStatsResponse represents the response body for cache statistics.
+type CacheStatsResponse struct {
+	cache.Status
+	HitRatio float64 `json:"hitRatio"`
+}
+
+// EvictResponse represents the response body for eviction requests.
+type EvictResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// Families lists the cache key families with their key counts and memory usage.
+func (c *AdminController) Families(ctx echo.Context) error {
+	families, err := c.cache.Families(ctx.Request().Context())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return ctx.JSON(http.StatusOK, families)
+}
+
+// Stats reports the cache hit/miss and write counters.
+func (c *AdminController) Stats(ctx echo.Context) error {
+	status := c.cache.Status()
+
+	return ctx.JSON(http.StatusOK, CacheStatsResponse{
+		Status:   status,
+		HitRatio: status.Reads.HitRatio(),
+	})
+}
+
+// EvictBook removes a book from the cache.
+func (c *AdminController) EvictBook(ctx echo.Context) error {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return errors.Wrap(books.ErrInvalidBookData, "invalid book ID")
+	}
+
+	if err := c.service.EvictBook(ctx.Request().Context(), id); err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// EvictAuthor removes the cached lists of an author.
+func (c *AdminController) EvictAuthor(ctx echo.Context) error {
+	deleted, err := c.service.EvictAuthor(ctx.Request().Context(), ctx.Param("author"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, EvictResponse{Deleted: deleted})
+}
+
+// EvictPrefix removes the cached entries whose key starts with the prefix
+// query parameter, e.g. ?prefix=books:books:*
+func (c *AdminController) EvictPrefix(ctx echo.Context) error {
+	prefix := ctx.QueryParam("prefix")
+	if prefix == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "prefix is required")
+	}
+	if !strings.HasPrefix(prefix, cache.KeyPrefix) {
+		return echo.NewHTTPError(http.StatusBadRequest, "prefix must start with "+cache.KeyPrefix)
+	}
+
+	deleted, err := c.service.EvictPrefix(ctx.Request().Context(), prefix)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, EvictResponse{Deleted: deleted})
+}
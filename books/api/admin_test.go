@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/books/testdata"
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Admin(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithDB().
+		WithCache()
+	defer suite.Close()
+
+	// setup returns an echo instance serving the admin routes with c
+	setup := func(c *cache.Cache) *echo.Echo {
+		e, repo, _ := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
+		e.HTTPErrorHandler = HTTPErrorHandler
+		newAdminController(books.NewBookService(repo, c), c).Routes(e.Group("/api/v1"))
+		return e
+	}
+
+	Convey("Cache administration while the cache is unavailable", t, func() {
+		e := setup(suite.UnavailableCache())
+
+		Convey("Report the cache state", func() {
+			var resp CacheStatsResponse
+			res := suite.Request(e, &testdata.Request{Method: "GET", Path: "/api/v1/admin/cache/stats"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.State, ShouldEqual, cache.StateConnecting)
+		})
+
+		Convey("Answer 503 to operations that need Redis", func() {
+			for _, r := range []testdata.Request{
+				{Method: "GET", Path: "/api/v1/admin/cache/families"},
+				{Method: "DELETE", Path: "/api/v1/admin/cache/books/1"},
+				{Method: "DELETE", Path: "/api/v1/admin/cache/authors/Author"},
+				{Method: "DELETE", Path: "/api/v1/admin/cache/keys?prefix=books:books:*"},
+			} {
+				var resp Problem
+				res := suite.Request(e, &r, &resp)
+				So(res.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+				So(resp.Type, ShouldEqual, "/problems/cache-unavailable")
+			}
+		})
+
+		Convey("Reject prefixes outside of the service's keys", func() {
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{Method: "DELETE", Path: "/api/v1/admin/cache/keys"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(resp.Detail, ShouldEqual, "prefix is required")
+
+			res = suite.Request(e, &testdata.Request{Method: "DELETE", Path: "/api/v1/admin/cache/keys?prefix=sessions:*"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(resp.Detail, ShouldEqual, "prefix must start with books:")
+		})
+	})
+
+	live := Convey
+	if suite.Cache() == nil {
+		live = SkipConvey
+	}
+	live("Cache administration", t, func() {
+		suite.ClearBooks()
+		ctx := context.Background()
+		c := suite.Cache()
+		e := setup(c)
+
+		book := suite.InsertBook(books.Book{
+			Title:       "Book",
+			Author:      "Author",
+			PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		byID := "books:books:getbyid:" + strconv.FormatInt(book.ID, 10)
+		So(c.Set(ctx, byID, book, time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getall:v0:author=Author", []books.Book{*book}, time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getall:v0:author=Other", []books.Book{}, time.Minute), ShouldBeNil)
+
+		cached := func(key string) bool {
+			var v interface{}
+			return c.Get(ctx, key, &v) == nil
+		}
+
+		Convey("List the key families", func() {
+			var resp []cache.FamilyUsage
+			res := suite.Request(e, &testdata.Request{Method: "GET", Path: "/api/v1/admin/cache/families"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+
+			keys := map[string]int64{}
+			for _, family := range resp {
+				keys[family.Family] = family.Keys
+				So(family.MemoryBytes, ShouldBeGreaterThan, 0)
+			}
+			So(keys["books:books:getbyid"], ShouldEqual, 1)
+			So(keys["books:books:getall"], ShouldEqual, 2)
+		})
+
+		Convey("Report the hit ratio", func() {
+			So(cached(byID), ShouldBeTrue)
+			So(cached("books:books:getbyid:0"), ShouldBeFalse)
+
+			var resp CacheStatsResponse
+			res := suite.Request(e, &testdata.Request{Method: "GET", Path: "/api/v1/admin/cache/stats"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.State, ShouldEqual, cache.StateConnected)
+			So(resp.Reads.Hits, ShouldBeGreaterThan, 0)
+			So(resp.HitRatio, ShouldBeBetween, 0, 1)
+		})
+
+		Convey("Evict a book", func() {
+			res := suite.Request(e, &testdata.Request{Method: "DELETE", Path: "/api/v1/admin/cache/books/" + strconv.FormatInt(book.ID, 10)})
+			So(res.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(cached(byID), ShouldBeFalse)
+		})
+
+		Convey("Evict the lists of an author", func() {
+			var resp EvictResponse
+			res := suite.Request(e, &testdata.Request{Method: "DELETE", Path: "/api/v1/admin/cache/authors/Author"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Deleted, ShouldEqual, 1)
+			So(cached("books:books:getall:v0:author=Other"), ShouldBeTrue)
+		})
+
+		Convey("Evict by prefix", func() {
+			var resp EvictResponse
+			res := suite.Request(e, &testdata.Request{Method: "DELETE", Path: "/api/v1/admin/cache/keys?prefix=books:books:getall:*"}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Deleted, ShouldEqual, 2)
+			So(cached(byID), ShouldBeTrue)
+		})
+	})
+}
@@ -1,23 +1,21 @@
 package api
 
 import (
+	"github.com/books/auth"
 	"github.com/books/books"
 	"github.com/books/books/cache"
-	"github.com/books/books/mysql"
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 )
 
-// InitRoutes initializes all API routes.
-func InitRoutes(g *echo.Group, db *sqlx.DB, c *cache.Cache) {
-	// Create repository provider
-	repoProvider := mysql.NewRepositoryProvider(db)
-
-	// Create service
-	bookService := books.NewBookService(repoProvider, c)
+// InitRoutes initializes all API routes. Requests are authenticated by
+// authenticator and each route checks the permission it requires.
+func InitRoutes(g *echo.Group, bookService *books.BookService, c *cache.Cache, authenticator *auth.Authenticator) {
+	g.Use(authenticator.Middleware())
 
 	// Create and register controllers
 	bookController := newBookController(bookService)
 	bookController.Routes(g)
-}
 
+	adminController := newAdminController(bookService, c)
+	adminController.Routes(g)
+}
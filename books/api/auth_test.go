@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/books/auth"
+	"github.com/books/auth/authtest"
+	"github.com/books/books"
+	"github.com/books/testdata"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_Auth(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithDB().
+		WithCache()
+	defer suite.Close()
+
+	Convey("Authorize requests by role", t, func() {
+		suite.ClearBooks()
+		suite.ClearAPIKeys()
+		viper.Set("auth.anonymous_role", "")
+		defer viper.Set("auth.anonymous_role", "")
+
+		e, _, service := suite.SetupAPI()
+		e.HTTPErrorHandler = HTTPErrorHandler
+		authenticator, err := auth.NewAuthenticator(suite.APIKeys())
+		So(err, ShouldBeNil)
+		InitRoutes(e.Group("/api/v1"), service, nil, authenticator)
+
+		book := suite.InsertBook(books.Book{
+			Title:       "Book",
+			Author:      "Author",
+			PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		bookPath := "/api/v1/books/" + strconv.FormatInt(book.ID, 10)
+		reader := suite.CreateAPIKey("reader", auth.RoleReader)
+		editor := suite.CreateAPIKey("editor", auth.RoleEditor)
+
+		request := func(method, path, key string) *testdata.Response {
+			r := &testdata.Request{Method: method, Path: path}
+			if key != "" {
+				r.Headers = map[string]string{"Authorization": "Bearer " + key}
+			}
+			return suite.Request(e, r)
+		}
+
+		Convey("Require credentials", func() {
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{Method: "GET", Path: bookPath}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(resp.Detail, ShouldEqual, "authentication required")
+
+			So(request("GET", bookPath, "bk_000000000000_00").StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Let readers read but not delete", func() {
+			So(request("GET", bookPath, reader).StatusCode, ShouldEqual, http.StatusOK)
+			So(request("DELETE", bookPath, reader).StatusCode, ShouldEqual, http.StatusForbidden)
+			So(request("GET", "/api/v1/admin/cache/stats", reader).StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Let editors delete", func() {
+			So(request("DELETE", bookPath, editor).StatusCode, ShouldEqual, http.StatusNoContent)
+		})
+
+		Convey("Reject revoked keys", func() {
+			keys, err := suite.APIKeys().List(context.Background())
+			So(err, ShouldBeNil)
+			So(suite.APIKeys().Revoke(context.Background(), keys[0].ID, time.Now()), ShouldBeNil)
+
+			So(request("GET", bookPath, reader).StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Give anonymous requests auth.anonymous_role", func() {
+			viper.Set("auth.anonymous_role", "reader")
+			e, _, service := suite.SetupAPI()
+			authenticator, err := auth.NewAuthenticator(suite.APIKeys())
+			So(err, ShouldBeNil)
+			InitRoutes(e.Group("/api/v1"), service, nil, authenticator)
+
+			So(suite.Request(e, &testdata.Request{Method: "GET", Path: bookPath}).StatusCode, ShouldEqual, http.StatusOK)
+			So(suite.Request(e, &testdata.Request{Method: "DELETE", Path: bookPath}).StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("Accept tokens of the OpenID Connect provider", func() {
+			idp := authtest.NewIdP()
+			defer idp.Close()
+			viper.Set("auth.jwt.discovery", true)
+			viper.Set("auth.jwt.issuer", idp.Issuer())
+			viper.Set("auth.jwt.audience", "books")
+			viper.Set("auth.jwt.role_claim", "groups")
+			viper.Set("auth.jwt.roles", []string{"staff=reader", "book-editors=editor"})
+			defer func() {
+				for _, key := range []string{"auth.jwt.discovery", "auth.jwt.issuer", "auth.jwt.audience", "auth.jwt.role_claim", "auth.jwt.roles"} {
+					viper.Set(key, nil)
+				}
+			}()
+
+			e, _, service := suite.SetupAPI()
+			authenticator, err := auth.NewAuthenticator(suite.APIKeys())
+			So(err, ShouldBeNil)
+			InitRoutes(e.Group("/api/v1"), service, nil, authenticator)
+
+			withToken := func(method, token string) int {
+				return suite.Request(e, &testdata.Request{
+					Method:  method,
+					Path:    bookPath,
+					Headers: map[string]string{"Authorization": "Bearer " + token},
+				}).StatusCode
+			}
+
+			staff := idp.Token(map[string]interface{}{"sub": "alice", "aud": "books", "groups": []string{"staff"}})
+			So(withToken("GET", staff), ShouldEqual, http.StatusOK)
+			So(withToken("DELETE", staff), ShouldEqual, http.StatusForbidden)
+
+			editor := idp.Token(map[string]interface{}{"sub": "bob", "aud": "books", "groups": []string{"staff", "book-editors"}})
+			So(withToken("DELETE", editor), ShouldEqual, http.StatusNoContent)
+		})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/books/auth"
 	"github.com/books/books"
 	"github.com/books/validate"
 	"github.com/labstack/echo/v4"
@@ -21,33 +22,37 @@ func newBookController(service *books.BookService) *BookController {
 	return &BookController{service: service}
 }
 
-// Routes sets up the routes for the book controller.
+// Routes sets up the routes for the book controller. Each route requires
+// the permission of its operation.
 func (c *BookController) Routes(g *echo.Group) {
 	api := g.Group("/books", ErrorHandler)
 
-	api.GET("", c.GetAll)
-	api.GET("/:id", c.GetByID)
-	api.POST("", c.Create)
-	api.PUT("/:id", c.Update)
-	api.DELETE("/:id", c.Delete)
+	api.GET("", c.GetAll, auth.Require(auth.PermReadBooks))
+	api.GET("/:id", c.GetByID, auth.Require(auth.PermReadBooks))
+	api.POST("", c.Create, auth.Require(auth.PermWriteBooks))
+	api.PUT("/:id", c.Update, auth.Require(auth.PermWriteBooks))
+	api.DELETE("/:id", c.Delete, auth.Require(auth.PermDeleteBooks))
 }
 
 // CreateBookRequest represents the request body for creating a book.
+// The limits keep values within the database columns: VARCHAR(255) titles
+// and authors, TEXT descriptions (64 KiB, up to 4 bytes per character) and
+// DATETIME dates between the years 1000 and 9999.
 type CreateBookRequest struct {
-	Title       string `json:"title"`
-	Author      string `json:"author"`
-	ISBN        string `json:"isbn"`
-	Description string `json:"description"`
-	PublishedAt string `json:"publishedAt"` // Format: "2006-01-02"
+	Title       string `json:"title" validate:"required,max=255"`
+	Author      string `json:"author" validate:"required,max=255"`
+	ISBN        string `json:"isbn" validate:"isbn"`
+	Description string `json:"description" validate:"max=10000"`
+	PublishedAt string `json:"publishedAt" validate:"required,date,datebetween=1000-01-01 9999-12-31"` // Format: "2006-01-02"
 }
 
 // UpdateBookRequest represents the request body for updating a book.
 type UpdateBookRequest struct {
-	Title       string `json:"title"`
-	Author      string `json:"author"`
-	ISBN        string `json:"isbn"`
-	Description string `json:"description"`
-	PublishedAt string `json:"publishedAt"` // Format: "2006-01-02"
+	Title       string `json:"title" validate:"required,max=255"`
+	Author      string `json:"author" validate:"required,max=255"`
+	ISBN        string `json:"isbn" validate:"isbn"`
+	Description string `json:"description" validate:"max=10000"`
+	PublishedAt string `json:"publishedAt" validate:"date,datebetween=1000-01-01 9999-12-31"` // Format: "2006-01-02"
 }
 
 // GetAllBooksResponse represents the response body for getting all books.
@@ -65,9 +70,7 @@ func (c *BookController) Create(ctx echo.Context) error {
 	}
 
 	v := validate.New()
-	v.Required("title", req.Title)
-	v.Required("author", req.Author)
-	v.Required("publishedAt", req.PublishedAt)
+	v.Struct(&req)
 	if v.HasErrors() {
 		return v
 	}
@@ -81,7 +84,7 @@ func (c *BookController) Create(ctx echo.Context) error {
 	book := books.Book{
 		Title:       req.Title,
 		Author:      req.Author,
-		ISBN:        req.ISBN,
+		ISBN:        validate.NormalizeISBN(req.ISBN),
 		Description: req.Description,
 		PublishedAt: publishedAt,
 	}
@@ -186,8 +189,7 @@ func (c *BookController) Update(ctx echo.Context) error {
 	}
 
 	v := validate.New()
-	v.Required("title", req.Title)
-	v.Required("author", req.Author)
+	v.Struct(&req)
 	if v.HasErrors() {
 		return v
 	}
@@ -205,7 +207,7 @@ func (c *BookController) Update(ctx echo.Context) error {
 	book := books.Book{
 		Title:       req.Title,
 		Author:      req.Author,
-		ISBN:        req.ISBN,
+		ISBN:        validate.NormalizeISBN(req.ISBN),
 		Description: req.Description,
 		PublishedAt: publishedAt,
 	}
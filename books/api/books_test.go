@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/books/auth"
 	"github.com/books/books"
 	"github.com/books/testdata"
 	"github.com/labstack/echo/v4"
@@ -21,6 +23,7 @@ func Test_Create(t *testing.T) {
 
 	Convey("POST /api/v1/books", t, func() {
 		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
 		apiGroup := e.Group("/api/v1")
 		controller := &BookController{service: service}
 		controller.Routes(apiGroup)
@@ -126,6 +129,42 @@ func Test_Create(t *testing.T) {
 			So(savedBook.Title, ShouldEqual, "Test Book")
 			So(savedBook.Author, ShouldEqual, "Test Author")
 		})
+
+		Convey("Return 400 when title is longer than 255 characters", func() {
+			suite.ClearBooks()
+
+			var resp echo.HTTPError
+			res := suite.Request(e, &testdata.Request{
+				Method: "POST",
+				Path:   "/api/v1/books",
+				Body: CreateBookRequest{
+					Title:       strings.Repeat("a", 256),
+					Author:      "Test Author",
+					PublishedAt: "2024-01-01",
+				},
+			}, &resp)
+
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Return 201 and store a hyphenated ISBN without separators", func() {
+			suite.ClearBooks()
+
+			var book books.Book
+			res := suite.Request(e, &testdata.Request{
+				Method: "POST",
+				Path:   "/api/v1/books",
+				Body: CreateBookRequest{
+					Title:       "The Great Gatsby",
+					Author:      "F. Scott Fitzgerald",
+					ISBN:        "978-0-7432-7356-5",
+					PublishedAt: "1925-04-10",
+				},
+			}, &book)
+
+			So(res.StatusCode, ShouldEqual, http.StatusCreated)
+			So(book.ISBN, ShouldEqual, "9780743273565")
+		})
 	})
 }
 
@@ -137,6 +176,7 @@ func Test_GetByID(t *testing.T) {
 
 	Convey("GET /api/v1/books/:id", t, func() {
 		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
 		apiGroup := e.Group("/api/v1")
 		controller := &BookController{service: service}
 		controller.Routes(apiGroup)
@@ -199,6 +239,7 @@ func Test_GetAll(t *testing.T) {
 
 	Convey("GET /api/v1/books", t, func() {
 		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
 		apiGroup := e.Group("/api/v1")
 		controller := &BookController{service: service}
 		controller.Routes(apiGroup)
@@ -547,6 +588,7 @@ func Test_Update(t *testing.T) {
 
 	Convey("PUT /api/v1/books/:id", t, func() {
 		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
 		apiGroup := e.Group("/api/v1")
 		controller := &BookController{service: service}
 		controller.Routes(apiGroup)
@@ -730,6 +772,7 @@ func Test_Delete(t *testing.T) {
 
 	Convey("DELETE /api/v1/books/:id", t, func() {
 		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
 		apiGroup := e.Group("/api/v1")
 		controller := &BookController{service: service}
 		controller.Routes(apiGroup)
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/books/books"
+	"github.com/labstack/echo/v4"
+)
+
+// primaryCookie holds the Unix time until which a client's reads go to the
+// primary database.
+const primaryCookie = "books_primary_until"
+
+// ReadYourWrites pins the reads of a request to the primary database once it
+// has written, so that it sees its own writes despite replication lag. When
+// pinFor is positive the pin also covers the client's requests for pinFor
+// after its last write, through a cookie.
+func ReadYourWrites(pinFor time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := books.WithReadPin(c.Request().Context())
+
+			if cookie, err := c.Cookie(primaryCookie); err == nil {
+				until, err := strconv.ParseInt(cookie.Value, 10, 64)
+				if err == nil && time.Now().Unix() < until {
+					books.PinReads(ctx)
+				}
+			}
+
+			if pinFor > 0 {
+				// Headers must be set before the response is written
+				c.Response().Before(func() {
+					if !books.Wrote(ctx) {
+						return
+					}
+					until := time.Now().Add(pinFor)
+					c.SetCookie(&http.Cookie{
+						Name:     primaryCookie,
+						Value:    strconv.FormatInt(until.Unix(), 10),
+						Path:     "/",
+						Expires:  until,
+						HttpOnly: true,
+						SameSite: http.SameSiteLaxMode,
+					})
+				})
+			}
+
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
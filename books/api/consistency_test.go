@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/books/books"
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_ReadYourWrites(t *testing.T) {
+	Convey("Read-your-writes pinning", t, func() {
+		e := echo.New()
+		e.Use(ReadYourWrites(time.Minute))
+
+		var pinned bool
+		e.GET("/books", func(c echo.Context) error {
+			pinned = books.PrimaryPinned(c.Request().Context())
+			return c.NoContent(http.StatusNoContent)
+		})
+		e.POST("/books", func(c echo.Context) error {
+			// What a repository does after a write
+			books.PinPrimary(c.Request().Context())
+			return c.NoContent(http.StatusNoContent)
+		})
+
+		serve := func(method string, cookie *http.Cookie) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(method, "/books", nil)
+			if cookie != nil {
+				r.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, r)
+			return w
+		}
+		primaryUntil := func(w *httptest.ResponseRecorder) *http.Cookie {
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.Name == primaryCookie {
+					return cookie
+				}
+			}
+			return nil
+		}
+
+		Convey("Pin the reads of a client after it writes", func() {
+			cookie := primaryUntil(serve(http.MethodPost, nil))
+			So(cookie, ShouldNotBeNil)
+
+			w := serve(http.MethodGet, cookie)
+			So(pinned, ShouldBeTrue)
+			So(primaryUntil(w), ShouldBeNil)
+		})
+
+		Convey("Leave the reads of other clients on the replicas", func() {
+			So(primaryUntil(serve(http.MethodGet, nil)), ShouldBeNil)
+			So(pinned, ShouldBeFalse)
+		})
+
+		Convey("Extend the pin when a pinned client writes again", func() {
+			soon := time.Now().Add(time.Second).Unix()
+			cookie := &http.Cookie{Name: primaryCookie, Value: strconv.FormatInt(soon, 10)}
+
+			renewed := primaryUntil(serve(http.MethodPost, cookie))
+			So(renewed, ShouldNotBeNil)
+			until, err := strconv.ParseInt(renewed.Value, 10, 64)
+			So(err, ShouldBeNil)
+			So(until, ShouldBeGreaterThan, soon)
+		})
+	})
+}
@@ -1,33 +1,22 @@
 package api
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
-	"github.com/books/books"
-	"github.com/books/validate"
+	"github.com/books/logging"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 )
 
 // getCodeByErr receives an error and returns its error code.
 func getCodeByErr(err error) int {
-	if _, ok := errors.Cause(err).(*validate.Validator); ok {
-		return http.StatusBadRequest
-	}
-
-	switch newError := err; newError {
-	case books.ErrInvalidBookData,
-		books.ErrBookAlreadyExists:
-		return http.StatusBadRequest
-	case books.ErrBookNotFound:
-		return http.StatusNotFound
-	default:
-		return http.StatusInternalServerError
-	}
+	return getProblemTypeByErr(errors.Cause(err)).status
 }
 
-// ErrorHandler is a middleware to handle errors in the api layer.
+// ErrorHandler is a middleware to handle errors in the api layer. Errors are
+// answered with problem details, or in the legacy shape when api.error_format
+// is legacy.
 func ErrorHandler(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		err := next(c)
@@ -36,14 +25,25 @@ func ErrorHandler(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		errCause := errors.Cause(err)
+
+		// Errors that already carry a status code (bind errors, middleware
+		// rejections) are passed through unchanged
+		if httpErr, ok := errCause.(*echo.HTTPError); ok {
+			return httpErr
+		}
+
+		ctx := c.Request().Context()
 		code := getCodeByErr(errCause)
 		if code == http.StatusInternalServerError {
-			log.Printf("Error: %v", err)
+			slog.ErrorContext(ctx, "request failed", "error", err.Error(), "stack", logging.Stack(err))
 		} else {
-			log.Printf("Info: %v", err)
+			slog.InfoContext(ctx, "request rejected", "status", code, "error", err.Error())
 		}
 
-		return echo.NewHTTPError(code, errCause.Error())
+		if legacyErrors() {
+			return echo.NewHTTPError(code, errCause.Error())
+		}
+		return writeProblem(c, newProblem(c, err))
 	}
 }
 
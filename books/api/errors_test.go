@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/books/auth"
+	"github.com/books/testdata"
+	"github.com/books/validate"
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_Errors(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithDB().
+		WithCache()
+	defer suite.Close()
+
+	Convey("Error responses", t, func() {
+		e, _, service := suite.SetupAPI()
+		suite.AuthenticateAs(e, auth.RoleAdmin)
+		e.HTTPErrorHandler = HTTPErrorHandler
+		apiGroup := e.Group("/api/v1")
+		controller := &BookController{service: service}
+		controller.Routes(apiGroup)
+
+		Convey("Return field errors of invalid requests", func() {
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{
+				Method: "POST",
+				Path:   "/api/v1/books",
+				Body:   CreateBookRequest{PublishedAt: "2024-01-01"},
+			}, &resp)
+
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(res.Header.Get(echo.HeaderContentType), ShouldStartWith, MIMEApplicationProblemJSON)
+			So(resp.Type, ShouldEqual, "/problems/validation-error")
+			So(resp.Status, ShouldEqual, http.StatusBadRequest)
+			So(resp.Errors, ShouldResemble, []validate.FieldError{
+				{Field: "title", Code: validate.CodeRequired, Message: "title is required"},
+				{Field: "author", Code: validate.CodeRequired, Message: "author is required"},
+			})
+		})
+
+		Convey("Return the problem type of domain errors", func() {
+			suite.ClearBooks()
+
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{
+				Method: "GET",
+				Path:   "/api/v1/books/999",
+			}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(resp.Type, ShouldEqual, "/problems/book-not-found")
+
+			res = suite.Request(e, &testdata.Request{
+				Method: "GET",
+				Path:   "/api/v1/books/invalid",
+			}, &resp)
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(resp.Type, ShouldEqual, "/problems/invalid-book-data")
+			So(resp.Detail, ShouldEqual, "invalid book ID")
+		})
+
+		Convey("Return about:blank problems for plain HTTP errors", func() {
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{
+				Method: "GET",
+				Path:   "/api/v1/unknown",
+			}, &resp)
+
+			So(res.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(resp.Type, ShouldEqual, "about:blank")
+			So(resp.Title, ShouldEqual, "Not Found")
+		})
+
+		Convey("Translate problems to the language of Accept-Language", func() {
+			var resp Problem
+			res := suite.Request(e, &testdata.Request{
+				Method:  "POST",
+				Path:    "/api/v1/books",
+				Body:    CreateBookRequest{Author: "Author", PublishedAt: "2024-01-01"},
+				Headers: map[string]string{headerAcceptLanguage: "fr-CA, en;q=0.5"},
+			}, &resp)
+
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(res.Header.Get(headerContentLanguage), ShouldEqual, "fr")
+			So(resp.Title, ShouldEqual, "La requête est invalide")
+			So(resp.Errors, ShouldResemble, []validate.FieldError{
+				{Field: "title", Code: validate.CodeRequired, Message: "Le champ titre est obligatoire"},
+			})
+
+			res = suite.Request(e, &testdata.Request{
+				Method:  "GET",
+				Path:    "/api/v1/books/invalid",
+				Headers: map[string]string{headerAcceptLanguage: "es"},
+			}, &resp)
+			So(res.Header.Get(headerContentLanguage), ShouldEqual, "en")
+			So(resp.Detail, ShouldEqual, "invalid book ID")
+		})
+
+		Convey("Keep the legacy shape behind api.error_format", func() {
+			viper.Set("api.error_format", ErrorFormatLegacy)
+			defer viper.Set("api.error_format", "")
+
+			var resp map[string]interface{}
+			res := suite.Request(e, &testdata.Request{
+				Method: "POST",
+				Path:   "/api/v1/books",
+				Body:   CreateBookRequest{PublishedAt: "2024-01-01"},
+			}, &resp)
+
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(res.Header.Get(echo.HeaderContentType), ShouldStartWith, echo.MIMEApplicationJSON)
+			So(resp, ShouldResemble, map[string]interface{}{
+				"message": "validation errors: title is required; author is required",
+			})
+		})
+	})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/books/i18n"
+	"github.com/books/logging"
+	"github.com/books/validate"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// MIMEApplicationProblemJSON is the content type of problem details.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Language negotiation headers, which echo does not define.
+const (
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+)
+
+// Error formats selected by api.error_format.
+const (
+	ErrorFormatProblem = "problem"
+	// ErrorFormatLegacy is the {"message": "..."} shape of earlier releases.
+	ErrorFormatLegacy = "legacy"
+)
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	// Type identifies the kind of problem; clients should branch on it
+	// rather than on Title or Detail.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the request ID, as in the X-Request-ID response header.
+	Instance string                `json:"instance,omitempty"`
+	Errors   []validate.FieldError `json:"errors,omitempty"`
+}
+
+// problemType is a kind of problem. Its URI is stable across releases.
+type problemType struct {
+	uri    string
+	title  string
+	status int
+}
+
+// Problem types of the API. Errors that only carry an HTTP status use
+// about:blank with the status text as title.
+var (
+	problemValidation       = problemType{"/problems/validation-error", "The request is invalid", http.StatusBadRequest}
+	problemInvalidBookData  = problemType{"/problems/invalid-book-data", "Invalid book data", http.StatusBadRequest}
+	problemBookNotFound     = problemType{"/problems/book-not-found", "Book not found", http.StatusNotFound}
+	problemBookExists       = problemType{"/problems/book-already-exists", "Book already exists", http.StatusBadRequest}
+	problemCacheUnavailable = problemType{"/problems/cache-unavailable", "Cache unavailable", http.StatusServiceUnavailable}
+	problemInternal         = problemType{"/problems/internal-error", "Internal server error", http.StatusInternalServerError}
+)
+
+// getProblemTypeByErr returns the problem type of an error cause.
+func getProblemTypeByErr(err error) problemType {
+	if _, ok := err.(*validate.Validator); ok {
+		return problemValidation
+	}
+
+	switch err {
+	case books.ErrInvalidBookData:
+		return problemInvalidBookData
+	case books.ErrBookAlreadyExists:
+		return problemBookExists
+	case books.ErrBookNotFound:
+		return problemBookNotFound
+	case cache.ErrUnavailable:
+		return problemCacheUnavailable
+	default:
+		return problemInternal
+	}
+}
+
+// legacyErrors reports whether errors use the legacy shape.
+func legacyErrors() bool {
+	return viper.GetString("api.error_format") == ErrorFormatLegacy
+}
+
+// newProblem returns the problem details of err.
+func newProblem(c echo.Context, err error) *Problem {
+	errCause := errors.Cause(err)
+	kind := getProblemTypeByErr(errCause)
+
+	problem := &Problem{
+		Type:     kind.uri,
+		Title:    kind.title,
+		Status:   kind.status,
+		Instance: logging.RequestID(c.Request().Context()),
+	}
+
+	switch kind {
+	case problemValidation:
+		problem.Errors = errCause.(*validate.Validator).Errors()
+	case problemInternal:
+		// Internal errors are logged, not shown to clients
+	default:
+		// The context added by errors.Wrap, e.g. "invalid book ID"
+		problem.Detail = strings.TrimSuffix(err.Error(), ": "+errCause.Error())
+	}
+	return problem
+}
+
+// newHTTPProblem returns the problem details of an echo.HTTPError.
+func newHTTPProblem(c echo.Context, httpErr *echo.HTTPError) *Problem {
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(httpErr.Code),
+		Status:   httpErr.Code,
+		Instance: logging.RequestID(c.Request().Context()),
+	}
+	if message, ok := httpErr.Message.(string); ok && message != problem.Title {
+		problem.Detail = message
+	}
+	return problem
+}
+
+// writeProblem writes problem as the response, in the language negotiated
+// from the Accept-Language header.
+func writeProblem(c echo.Context, problem *Problem) error {
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(problem.Status)
+	}
+
+	catalog := i18n.Negotiate(c.Request().Header.Get(headerAcceptLanguage))
+	localize(problem, catalog)
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	header.Set(headerContentLanguage, catalog.Lang())
+	header.Add(echo.HeaderVary, headerAcceptLanguage)
+	return c.JSON(problem.Status, problem)
+}
+
+// localize translates the title, detail and field errors of problem with
+// catalog. Messages that no catalog has, e.g. those of custom validation
+// rules, are kept as they are.
+func localize(problem *Problem, catalog *i18n.Catalog) {
+	section, key := i18n.SectionProblems, strings.TrimPrefix(problem.Type, "/problems/")
+	if problem.Type == "about:blank" {
+		section, key = i18n.SectionHTTP, strconv.Itoa(problem.Status)
+	}
+	if title, ok := catalog.Message(section, key, nil); ok {
+		problem.Title = title
+	}
+
+	if problem.Detail != "" {
+		if detail, ok := catalog.Message(i18n.SectionDetails, problem.Detail, nil); ok {
+			problem.Detail = detail
+		}
+	}
+
+	// The errors belong to the validator; translate a copy
+	fieldErrors := make([]validate.FieldError, len(problem.Errors))
+	for i, fieldErr := range problem.Errors {
+		params := map[string]string{"field": fieldErr.Field}
+		if name, ok := catalog.Message(i18n.SectionFields, fieldErr.Field, nil); ok {
+			params["field"] = name
+		}
+		for name, value := range fieldErr.Params {
+			params[name] = value
+		}
+
+		if message, ok := catalog.Message(i18n.SectionValidation, fieldErr.Code, params); ok {
+			fieldErr.Message = message
+		}
+		fieldErrors[i] = fieldErr
+	}
+	if len(fieldErrors) > 0 {
+		problem.Errors = fieldErrors
+	}
+}
+
+// HTTPErrorHandler writes the errors that reach echo, e.g. unknown routes and
+// rejected credentials, as problem details. With api.error_format legacy it
+// falls back to echo's handler.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if legacyErrors() {
+		c.Echo().DefaultHTTPErrorHandler(err, c)
+		return
+	}
+	if c.Response().Committed {
+		return
+	}
+
+	var problem *Problem
+	if httpErr, ok := errors.Cause(err).(*echo.HTTPError); ok {
+		if inner, ok := httpErr.Internal.(*echo.HTTPError); ok {
+			httpErr = inner
+		}
+		problem = newHTTPProblem(c, httpErr)
+	} else {
+		problem = newProblem(c, err)
+	}
+
+	if err := writeProblem(c, problem); err != nil {
+		c.Logger().Error(err)
+	}
+}
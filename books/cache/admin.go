@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix is the namespace of every key written by this service. Admin
+// operations never touch keys outside of it, so other data in the same Redis
+// database is left alone.
+const KeyPrefix = "books:"
+
+// scanCount is the SCAN batch size hint.
+const scanCount = 500
+
+// FamilyUsage describes the keys sharing a prefix.
+type FamilyUsage struct {
+	// Family is the first three segments of the key, e.g. books:books:getbyid.
+	Family string `json:"family"`
+	// Keys is the number of keys in the family.
+	Keys int64 `json:"keys"`
+	// MemoryBytes is the memory used by the keys as reported by MEMORY USAGE.
+	MemoryBytes int64 `json:"memoryBytes"`
+}
+
+// ReadStats holds cache read counters.
+type ReadStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// HitRatio returns hits / (hits + misses), or 0 before any read.
+func (s ReadStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// ReadStats returns a snapshot of the read counters.
+func (c *Cache) ReadStats() ReadStats {
+	return ReadStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.readErrors.Load(),
+	}
+}
+
+// Families lists the key families under KeyPrefix with their key counts and
+// memory usage. It uses SCAN, so it does not block Redis on large databases.
+func (c *Cache) Families(ctx context.Context) ([]FamilyUsage, error) {
+	usage := make(map[string]*FamilyUsage)
+
+	err := c.scan(ctx, KeyPrefix+"*", func(client redis.Cmdable, keys []string) error {
+		pipe := client.Pipeline()
+		cmds := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.MemoryUsage(ctx, key)
+		}
+		// Keys may expire between SCAN and MEMORY USAGE
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return err
+		}
+
+		for i, key := range keys {
+			family := keyFamily(key)
+			u, ok := usage[family]
+			if !ok {
+				u = &FamilyUsage{Family: family}
+				usage[family] = u
+			}
+			u.Keys++
+			u.MemoryBytes += cmds[i].Val()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	families := make([]FamilyUsage, 0, len(usage))
+	for _, u := range usage {
+		families = append(families, *u)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Family < families[j].Family
+	})
+	return families, nil
+}
+
+// DeleteByPrefix deletes every key starting with prefix for which keep
+// returns false (all of them if keep is nil) and returns how many were
+// removed. The prefix must be inside KeyPrefix; a trailing * is ignored.
+func (c *Cache) DeleteByPrefix(ctx context.Context, prefix string, keep func(key string) bool) (int64, error) {
+	prefix = strings.TrimSuffix(prefix, "*")
+	if !strings.HasPrefix(prefix, KeyPrefix) {
+		return 0, fmt.Errorf("prefix must start with %q", KeyPrefix)
+	}
+
+	return c.DeleteMatching(ctx, escapePattern(prefix)+"*", keep)
+}
+
+// DeleteMatching deletes the keys matching the SCAN pattern for which keep
+// returns false (all of them if keep is nil) and returns how many were removed.
+func (c *Cache) DeleteMatching(ctx context.Context, pattern string, keep func(key string) bool) (int64, error) {
+	if !strings.HasPrefix(pattern, KeyPrefix) {
+		return 0, fmt.Errorf("pattern must start with %q", KeyPrefix)
+	}
+
+	var deleted int64
+	err := c.scan(ctx, pattern, func(client redis.Cmdable, keys []string) error {
+		// Delete one key per command so that cluster pipelines can route them
+		pipe := client.Pipeline()
+		cmds := make([]*redis.IntCmd, 0, len(keys))
+		for _, key := range keys {
+			if keep != nil && keep(key) {
+				continue
+			}
+			cmds = append(cmds, pipe.Del(ctx, key))
+		}
+		if len(cmds) == 0 {
+			return nil
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			deleted += cmd.Val()
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// scan calls fn with batches of keys matching pattern. On a cluster every
+// master is scanned.
+func (c *Cache) scan(ctx context.Context, pattern string, fn func(client redis.Cmdable, keys []string) error) error {
+	// Masters are scanned concurrently; fn is not expected to be goroutine safe
+	var mu sync.Mutex
+	scanNode := func(ctx context.Context, client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, pattern, scanCount).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				mu.Lock()
+				err := fn(client, keys)
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	return c.do(ctx, func() error {
+		if cluster, ok := c.client.(*redis.ClusterClient); ok {
+			return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+				return scanNode(ctx, client)
+			})
+		}
+		return scanNode(ctx, c.client)
+	})
+}
+
+// keyFamily returns the first three colon separated segments of key.
+func keyFamily(key string) string {
+	parts := strings.SplitN(key, ":", 4)
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ":")
+}
+
+// escapePattern escapes the glob characters understood by SCAN MATCH.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
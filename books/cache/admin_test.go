@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_AdminHelpers(t *testing.T) {
+	Convey("Cache administration helpers", t, func() {
+		Convey("Group keys by their first three segments", func() {
+			So(keyFamily("books:books:getbyid:42"), ShouldEqual, "books:books:getbyid")
+			So(keyFamily("books:books:getall:v3:author=Jane"), ShouldEqual, "books:books:getall")
+			So(keyFamily("books:books:popular"), ShouldEqual, "books:books:popular")
+		})
+
+		Convey("Escape SCAN glob characters", func() {
+			So(escapePattern(`books:a*b?[c]\`), ShouldEqual, `books:a\*b\?\[c\]\\`)
+		})
+
+		Convey("Compute the hit ratio", func() {
+			So(ReadStats{}.HitRatio(), ShouldEqual, 0)
+			So(ReadStats{Hits: 3, Misses: 1}.HitRatio(), ShouldEqual, 0.75)
+		})
+	})
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Breaker(t *testing.T) {
+	Convey("Cache circuit breaker", t, func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		b := newBreaker(3, 30*time.Second)
+		b.now = func() time.Time { return now }
+		errTimeout := errors.New("i/o timeout")
+
+		Convey("Stay closed below the failure threshold", func() {
+			b.failure(errTimeout)
+			b.failure(errTimeout)
+			So(b.allow(), ShouldBeTrue)
+
+			b.success()
+			b.failure(errTimeout)
+			b.failure(errTimeout)
+			So(b.allow(), ShouldBeTrue)
+		})
+
+		Convey("Open after consecutive failures", func() {
+			for i := 0; i < 3; i++ {
+				b.failure(errTimeout)
+			}
+			So(b.allow(), ShouldBeFalse)
+
+			state, failures, lastErr := b.status()
+			So(state, ShouldEqual, BreakerOpen)
+			So(failures, ShouldEqual, 3)
+			So(lastErr, ShouldEqual, errTimeout)
+
+			Convey("Let a single trial call through after the cooldown", func() {
+				now = now.Add(31 * time.Second)
+				So(b.allow(), ShouldBeTrue)
+				So(b.allow(), ShouldBeFalse)
+
+				Convey("Close when the trial succeeds", func() {
+					b.success()
+					So(b.allow(), ShouldBeTrue)
+					state, _, _ := b.status()
+					So(state, ShouldEqual, BreakerClosed)
+				})
+
+				Convey("Re-open when the trial fails", func() {
+					b.failure(errTimeout)
+					So(b.allow(), ShouldBeFalse)
+					state, _, _ := b.status()
+					So(state, ShouldEqual, BreakerOpen)
+				})
+			})
+		})
+	})
+}
+
+func Test_CacheBreaker(t *testing.T) {
+	Convey("Cache calls and the circuit breaker", t, func() {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		c := &Cache{breaker: newBreaker(1, 30*time.Second)}
+		c.breaker.now = func() time.Time { return now }
+		c.state.Store(StateConnected)
+
+		// call runs a Redis call that fails with the error of its context.
+		call := func(ctx context.Context) error {
+			return c.do(ctx, func() error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		}
+		timedOut := func() context.Context {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			t.Cleanup(cancel)
+			return ctx
+		}
+		cancelled := func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}
+
+		Convey("Count timeouts as failures", func() {
+			So(call(timedOut()), ShouldEqual, context.DeadlineExceeded)
+			So(c.Available(), ShouldBeFalse)
+		})
+
+		Convey("Ignore calls cancelled by the caller", func() {
+			So(call(cancelled()), ShouldEqual, context.Canceled)
+			So(c.Available(), ShouldBeTrue)
+		})
+
+		Convey("Re-open when the trial call times out", func() {
+			So(call(timedOut()), ShouldNotBeNil)
+			now = now.Add(31 * time.Second)
+
+			So(call(timedOut()), ShouldEqual, context.DeadlineExceeded)
+			state, _, _ := c.breaker.status()
+			So(state, ShouldEqual, BreakerOpen)
+			So(c.do(context.Background(), func() error { return nil }), ShouldEqual, ErrUnavailable)
+		})
+
+		Convey("Close once connect succeeds", func() {
+			c.ready = make(chan struct{})
+			c.breaker.failure(errors.New("connection refused"))
+			So(c.Available(), ShouldBeFalse)
+
+			c.markConnected()
+			So(c.Available(), ShouldBeTrue)
+		})
+
+		Convey("Free the trial slot when the trial call is cancelled", func() {
+			So(call(timedOut()), ShouldNotBeNil)
+			now = now.Add(31 * time.Second)
+
+			So(call(cancelled()), ShouldEqual, context.Canceled)
+			So(c.do(context.Background(), func() error { return nil }), ShouldBeNil)
+			state, _, _ := c.breaker.status()
+			So(state, ShouldEqual, BreakerClosed)
+		})
+	})
+}
@@ -2,74 +2,551 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrCacheMiss is returned when a key is not found in the cache.
 var ErrCacheMiss = errors.New("cache miss")
 
+// ErrUnavailable is returned without contacting Redis while the cache is not
+// connected yet or its circuit breaker is open.
+var ErrUnavailable = errors.New("cache unavailable")
+
+// Family identifies a group of cache keys that share the same TTL.
+type Family string
+
+const (
+	// FamilyByID holds single entities looked up by ID.
+	FamilyByID Family = "byid"
+	// FamilyList holds list results.
+	FamilyList Family = "list"
+)
+
+// defaultTTL is used for families without a configured TTL.
+const defaultTTL = time.Hour * 1
+
+// defaultPopularSize is the number of members kept in hit sorted sets when
+// cache.warm.popular_size is not set.
+const defaultPopularSize = 1000
+
+// defaultCompressionThreshold is the value size in bytes above which values are
+// compressed when cache.compression.threshold is not set.
+const defaultCompressionThreshold = 64 * 1024
+
+// Connection states reported by Status.
+const (
+	StateConnecting = "connecting"
+	StateConnected  = "connected"
+	StateClosed     = "closed"
+)
+
+// Status describes the health of the cache.
+type Status struct {
+	// State is connecting until the first successful ping, then connected.
+	State string `json:"state"`
+	// Breaker is the circuit breaker state.
+	Breaker string `json:"breaker"`
+	// ConsecutiveFailures is the number of Redis calls that failed in a row.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// LastError is the last Redis error, if any.
+	LastError string `json:"lastError,omitempty"`
+	// Reads holds the hit/miss counters.
+	Reads ReadStats `json:"reads"`
+	// Writes holds the background write counters.
+	Writes Stats `json:"writes"`
+}
+
 // Cache wraps a Redis client and provides caching functionality.
 type Cache struct {
-	client *redis.Client
+	client redis.UniversalClient
+	codec  codec
+	ttls   map[Family]time.Duration
+
+	// compressThreshold is the size in bytes above which values are
+	// compressed. 0 disables compression.
+	compressThreshold int
+
+	// writer applies SetAsync and Invalidate writes in the background.
+	writer *writer
+
+	// syncInvalidation makes Invalidate apply writes before returning
+	// instead of queueing them.
+	syncInvalidation bool
+
+	// breaker short-circuits calls during Redis outages.
+	breaker *breaker
+
+	// missed holds the invalidations to replay before Redis is used again.
+	missed missedInvalidations
+
+	// popularSize bounds the sorted sets written by RecordHit. 0 disables
+	// trimming.
+	popularSize int64
+
+	// state is one of the State* constants.
+	state atomic.Value
+
+	// stop cancels the background reconnect loop.
+	stop context.CancelFunc
+
+	// Read counters reported by ReadStats.
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	readErrors atomic.Uint64
+
+	// ready is closed once Redis has answered a ping.
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
 // NewCache creates a new Cache instance.
+// It fails if Redis cannot be reached.
 func NewCache() (*Cache, error) {
-	dsn := viper.GetString("redis.dsn")
-	if dsn == "" {
-		dsn = "127.0.0.1:6379"
-	}
-
-	opts, err := redis.ParseURL("redis://" + dsn)
+	c, err := newCache()
 	if err != nil {
-		opts = &redis.Options{Addr: dsn}
+		return nil, err
 	}
 
-	client := redis.NewClient(opts)
-	
 	// Use a context with timeout for the ping to avoid hanging
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
-	
-	if err := client.Ping(ctx).Err(); err != nil {
+
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		_ = c.Close(ctx)
+		return nil, err
+	}
+
+	c.markConnected()
+	return c, nil
+}
+
+// NewReconnectingCache creates a Cache that starts in degraded mode and
+// connects to Redis in the background, retrying with exponential backoff.
+// Until the first successful ping every call returns ErrUnavailable.
+// It only fails on invalid configuration.
+func NewReconnectingCache() (*Cache, error) {
+	c, err := newCache()
+	if err != nil {
+		return nil, err
+	}
+
+	minBackoff := viper.GetDuration("cache.reconnect.min_backoff")
+	if minBackoff <= 0 {
+		minBackoff = time.Second
+	}
+	maxBackoff := viper.GetDuration("cache.reconnect.max_backoff")
+	if maxBackoff < minBackoff {
+		maxBackoff = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop = cancel
+	go c.connect(ctx, minBackoff, maxBackoff)
+
+	return c, nil
+}
+
+// redisLogger sends the internal logs of go-redis, mostly dial failures
+// already reported by connect, to slog at the debug level.
+type redisLogger struct{}
+
+// Printf logs a go-redis message.
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	slog.DebugContext(ctx, "redis: "+fmt.Sprintf(format, v...))
+}
+
+func init() {
+	redis.SetLogger(redisLogger{})
+}
+
+// newCache builds a Cache from config without contacting Redis.
+func newCache() (*Cache, error) {
+	cfg, err := LoadRedisConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := cfg.UniversalOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := codecByName(viper.GetString("cache.encoding"))
+	if err != nil {
 		return nil, err
 	}
 
-	return &Cache{client: client}, nil
+	threshold := defaultCompressionThreshold
+	if viper.IsSet("cache.compression.threshold") {
+		threshold = viper.GetInt("cache.compression.threshold")
+	}
+
+	ttls := make(map[Family]time.Duration)
+	for _, family := range []Family{FamilyByID, FamilyList} {
+		ttl := viper.GetDuration("cache.ttl." + string(family))
+		if ttl <= 0 {
+			ttl = defaultTTL
+		}
+		ttls[family] = ttl
+	}
+
+	breakerThreshold := 5
+	if viper.IsSet("cache.breaker.threshold") {
+		breakerThreshold = viper.GetInt("cache.breaker.threshold")
+	}
+	cooldown := viper.GetDuration("cache.breaker.cooldown")
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+
+	popularSize := int64(defaultPopularSize)
+	if viper.IsSet("cache.warm.popular_size") {
+		popularSize = viper.GetInt64("cache.warm.popular_size")
+	}
+
+	client := redis.NewUniversalClient(opts)
+	client.AddHook(tracingHook{})
+
+	cache := &Cache{
+		client:            client,
+		codec:             c,
+		ttls:              ttls,
+		compressThreshold: threshold,
+		writer:            newWriter(writerConfig()),
+		syncInvalidation:  viper.GetBool("cache.write.sync_invalidation"),
+		breaker:           newBreaker(breakerThreshold, cooldown),
+		popularSize:       popularSize,
+		stop:              func() {},
+		ready:             make(chan struct{}),
+	}
+	cache.state.Store(StateConnecting)
+	return cache, nil
+}
+
+// connect pings Redis until it answers or ctx is done.
+func (c *Cache) connect(ctx context.Context, minBackoff, maxBackoff time.Duration) {
+	backoff := minBackoff
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		err := c.client.Ping(pingCtx).Err()
+		cancel()
+		if err == nil {
+			c.markConnected()
+			slog.Info("connected to Redis cache")
+			return
+		}
+		if ctx.Err() != nil {
+			// Closed while connecting
+			return
+		}
+
+		c.breaker.failure(err)
+		slog.Warn("Redis cache unavailable, retrying", "backoff", backoff.String(), "error", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// markConnected records the first successful ping. It closes the breaker
+// opened by the failed pings of connect.
+func (c *Cache) markConnected() {
+	c.breaker.success()
+	c.state.Store(StateConnected)
+	c.readyOnce.Do(func() { close(c.ready) })
+}
+
+// Ready returns a channel that is closed once the cache has connected.
+func (c *Cache) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// do runs fn if the cache is connected and its breaker allows it, and records
+// the outcome on the breaker. Missed invalidations are applied first, and fn
+// is not run if that fails.
+func (c *Cache) do(ctx context.Context, fn func() error) error {
+	if c.state.Load() != StateConnected || !c.breaker.allow() {
+		trace.SpanFromContext(ctx).AddEvent("cache unavailable")
+		return ErrUnavailable
+	}
+
+	err := c.missed.apply(ctx, c.client)
+	if err == nil {
+		err = fn()
+	}
+	switch {
+	case err == nil, err == redis.Nil:
+		c.breaker.success()
+	case errors.Is(ctx.Err(), context.Canceled):
+		// The caller gave up; that says nothing about Redis. Timeouts do
+		// count as failures, since a hung Redis only shows up as those.
+		c.breaker.release()
+	default:
+		c.breaker.failure(err)
+	}
+	return err
+}
+
+// Status reports the connection, breaker and write counters.
+func (c *Cache) Status() Status {
+	breakerState, failures, lastErr := c.breaker.status()
+	status := Status{
+		State:               c.state.Load().(string),
+		Breaker:             breakerState,
+		ConsecutiveFailures: failures,
+		Reads:               c.ReadStats(),
+		Writes:              c.Stats(),
+	}
+	if lastErr != nil {
+		status.LastError = lastErr.Error()
+	}
+	return status
+}
+
+// Available reports whether calls are currently sent to Redis.
+func (c *Cache) Available() bool {
+	breakerState, _, _ := c.breaker.status()
+	return c.state.Load() == StateConnected && breakerState != BreakerOpen
+}
+
+// Ping checks that Redis answers. Unlike other calls it bypasses the circuit
+// breaker, so health checks report the actual state of Redis.
+func (c *Cache) Ping(ctx context.Context) error {
+	if c.state.Load() == StateClosed {
+		return ErrUnavailable
+	}
+	return c.client.Ping(ctx).Err()
+}
+
+// writerConfig reads the write-behind worker options from config.
+func writerConfig() WriterConfig {
+	cfg := WriterConfig{
+		Workers:      4,
+		QueueSize:    1024,
+		Retries:      3,
+		RetryBackoff: 50 * time.Millisecond,
+		Timeout:      time.Second,
+	}
+	if viper.IsSet("cache.write.workers") {
+		cfg.Workers = viper.GetInt("cache.write.workers")
+	}
+	if viper.IsSet("cache.write.queue_size") {
+		cfg.QueueSize = viper.GetInt("cache.write.queue_size")
+	}
+	if viper.IsSet("cache.write.retries") {
+		cfg.Retries = viper.GetInt("cache.write.retries")
+	}
+	if viper.IsSet("cache.write.retry_backoff") {
+		cfg.RetryBackoff = viper.GetDuration("cache.write.retry_backoff")
+	}
+	if viper.IsSet("cache.write.timeout") {
+		cfg.Timeout = viper.GetDuration("cache.write.timeout")
+	}
+	return cfg
+}
+
+// TTL returns the configured expiration for a key family.
+func (c *Cache) TTL(family Family) time.Duration {
+	if ttl, ok := c.ttls[family]; ok {
+		return ttl
+	}
+	return defaultTTL
 }
 
 // Get retrieves a value from the cache.
 func (c *Cache) Get(ctx context.Context, key string, v interface{}) error {
-	val, err := c.client.Get(ctx, key).Result()
+	var val []byte
+	err := c.do(ctx, func() (err error) {
+		val, err = c.client.Get(ctx, key).Bytes()
+		return err
+	})
 	if err == redis.Nil {
+		c.misses.Add(1)
 		return ErrCacheMiss
 	}
 	if err != nil {
+		c.readErrors.Add(1)
 		return err
 	}
-	return json.Unmarshal([]byte(val), v)
+	if err := decode(val, v); err != nil {
+		c.readErrors.Add(1)
+		return err
+	}
+	c.hits.Add(1)
+	return nil
 }
 
 // Set sets a cache key to the provided value with expiration.
 func (c *Cache) Set(ctx context.Context, key string, value interface{}, expires time.Duration) error {
-	data, err := json.Marshal(value)
+	data, err := encode(c.codec, c.compressThreshold, value)
 	if err != nil {
 		return err
 	}
-	return c.client.Set(ctx, key, data, expires).Err()
+	return c.do(ctx, func() error {
+		return c.client.Set(ctx, key, data, expires).Err()
+	})
+}
+
+// SetAsync queues a Set on the write-behind worker. The value is encoded
+// before returning, so later changes to it are not reflected in the cache.
+func (c *Cache) SetAsync(ctx context.Context, key string, value interface{}, expires time.Duration) error {
+	data, err := encode(c.codec, c.compressThreshold, value)
+	if err != nil {
+		return err
+	}
+	return c.writer.enqueue(writeOp{
+		ctx:  context.WithoutCancel(ctx),
+		name: "set",
+		key:  key,
+		fn: func(ctx context.Context) error {
+			return c.do(ctx, func() error {
+				return c.client.Set(ctx, key, data, expires).Err()
+			})
+		},
+	})
+}
+
+// Invalidate deletes keys and increments version counters in one pipeline.
+// It is not a transaction, since in cluster mode the keys may live in
+// different slots; every command touches a single key. When
+// cache.write.sync_invalidation is enabled the writes are applied, with
+// retries, before returning; otherwise they are queued on the write-behind
+// worker. Invalidations that are dropped or fail are remembered and applied
+// before Redis is used again.
+func (c *Cache) Invalidate(ctx context.Context, keys []string, counters []string) error {
+	op := writeOp{
+		lost: func() { c.missed.add(keys, counters) },
+		ctx:  context.WithoutCancel(ctx),
+		name: "invalidate",
+		key:  strings.Join(append(append([]string{}, keys...), counters...), ","),
+		fn: func(ctx context.Context) error {
+			return c.do(ctx, func() error {
+				_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+					for _, key := range keys {
+						pipe.Del(ctx, key)
+					}
+					for _, counter := range counters {
+						pipe.Incr(ctx, counter)
+					}
+					return nil
+				})
+				return err
+			})
+		},
+	}
+
+	if c.syncInvalidation {
+		return c.writer.do(ctx, op)
+	}
+	return c.writer.enqueue(op)
+}
+
+// Stats returns a snapshot of the cache write counters.
+func (c *Cache) Stats() Stats {
+	return c.writer.stats()
+}
+
+// Drain stops accepting background writes and waits until the queued ones
+// have been applied or ctx is done.
+func (c *Cache) Drain(ctx context.Context) error {
+	return c.writer.drain(ctx)
+}
+
+// Close stops reconnecting, drains pending writes and closes the Redis client.
+func (c *Cache) Close(ctx context.Context) error {
+	c.stop()
+	drainErr := c.Drain(ctx)
+	c.state.Store(StateClosed)
+	if err := c.client.Close(); err != nil {
+		return err
+	}
+	return drainErr
+}
+
+// RecordHit asynchronously increments the score of member in the sorted set
+// at key. It is used to track how often entities are requested. The set is
+// trimmed to its cache.warm.popular_size highest scoring members.
+func (c *Cache) RecordHit(ctx context.Context, key, member string) error {
+	return c.writer.enqueue(writeOp{
+		ctx:  context.WithoutCancel(ctx),
+		name: "zincrby",
+		key:  key,
+		fn: func(ctx context.Context) error {
+			return c.do(ctx, func() error {
+				_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.ZIncrBy(ctx, key, 1, member)
+					if c.popularSize > 0 {
+						pipe.ZRemRangeByRank(ctx, key, 0, -c.popularSize-1)
+					}
+					return nil
+				})
+				return err
+			})
+		},
+	})
+}
+
+// TopHits returns up to n members of the sorted set at key, highest score first.
+func (c *Cache) TopHits(ctx context.Context, key string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	var members []string
+	err := c.do(ctx, func() (err error) {
+		members, err = c.client.ZRevRange(ctx, key, 0, int64(n-1)).Result()
+		return err
+	})
+	return members, err
+}
+
+// Counter returns the integer value stored at key, or 0 if it does not exist.
+func (c *Cache) Counter(ctx context.Context, key string) (int64, error) {
+	var val int64
+	err := c.do(ctx, func() (err error) {
+		val, err = c.client.Get(ctx, key).Int64()
+		return err
+	})
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return val, err
+}
+
+// Incr atomically increments the integer value stored at key.
+func (c *Cache) Incr(ctx context.Context, key string) error {
+	return c.do(ctx, func() error {
+		return c.client.Incr(ctx, key).Err()
+	})
 }
 
 // Delete removes a key from the cache.
 func (c *Cache) Delete(ctx context.Context, key string) error {
-	return c.client.Del(ctx, key).Err()
+	return c.do(ctx, func() error {
+		return c.client.Del(ctx, key).Err()
+	})
 }
 
 // FlushDB flushes the entire Redis database.
 func (c *Cache) FlushDB(ctx context.Context) error {
-	return c.client.FlushDB(ctx).Err()
+	return c.do(ctx, func() error {
+		return c.client.FlushDB(ctx).Err()
+	})
 }
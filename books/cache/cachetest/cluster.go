@@ -0,0 +1,382 @@
+// Package cachetest contains a stand-in Redis Cluster for tests of the cache
+// in cluster mode.
+package cachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Cluster is a single in-memory Redis Cluster node that owns every slot. It
+// speaks enough of RESP2 for the cache: strings, counters, DEL, SCAN and
+// MULTI. Like a real cluster it rejects commands and transactions whose keys
+// hash to more than one slot. Expirations are ignored. It also stands in for
+// a single Redis server.
+type Cluster struct {
+	listener net.Listener
+
+	// down makes the node close every connection, as a stopped Redis would.
+	down atomic.Bool
+
+	mu     sync.Mutex
+	values map[string]string
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewCluster starts a cluster node on a local port. Close it when done.
+func NewCluster() *Cluster {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	c := &Cluster{
+		listener: listener,
+		values:   map[string]string{},
+		conns:    map[net.Conn]struct{}{},
+	}
+	c.wg.Add(1)
+	go c.serve()
+	return c
+}
+
+// Addr returns the host:port of the node, to be used as a seed address.
+func (c *Cluster) Addr() string {
+	return c.listener.Addr().String()
+}
+
+// Get returns the value stored at key.
+func (c *Cluster) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// SetDown makes the node unreachable until it is called with false.
+func (c *Cluster) SetDown(down bool) {
+	c.down.Store(down)
+	if down {
+		c.mu.Lock()
+		for conn := range c.conns {
+			_ = conn.Close()
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Close shuts the node down and drops its connections.
+func (c *Cluster) Close() {
+	c.mu.Lock()
+	c.closed = true
+	for conn := range c.conns {
+		_ = conn.Close()
+	}
+	c.mu.Unlock()
+
+	_ = c.listener.Close()
+	c.wg.Wait()
+}
+
+// serve accepts connections until the listener is closed.
+func (c *Cluster) serve() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		if c.down.Load() {
+			_ = conn.Close()
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		c.conns[conn] = struct{}{}
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go c.handle(conn)
+	}
+}
+
+// handle answers the commands of one connection.
+func (c *Cluster) handle(conn net.Conn) {
+	defer c.wg.Done()
+	defer func() {
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	// queued holds the commands of an open MULTI, nil outside of one.
+	var queued [][]string
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			queued = [][]string{}
+			writeSimple(w, "OK")
+		case name == "EXEC" && queued != nil:
+			c.exec(w, queued)
+			queued = nil
+		case name == "DISCARD" && queued != nil:
+			queued = nil
+			writeSimple(w, "OK")
+		case queued != nil:
+			queued = append(queued, args)
+			writeSimple(w, "QUEUED")
+		default:
+			c.mu.Lock()
+			reply := c.run(args)
+			c.mu.Unlock()
+			reply(w)
+		}
+
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs the commands of a transaction if their keys share a slot.
+func (c *Cluster) exec(w *bufio.Writer, cmds [][]string) {
+	slot := -1
+	for _, args := range cmds {
+		for _, key := range keys(args) {
+			s := Slot(key)
+			if slot >= 0 && s != slot {
+				writeError(w, "CROSSSLOT Keys in request don't hash to the same slot")
+				return
+			}
+			slot = s
+		}
+	}
+
+	c.mu.Lock()
+	replies := make([]func(*bufio.Writer), 0, len(cmds))
+	for _, args := range cmds {
+		replies = append(replies, c.run(args))
+	}
+	c.mu.Unlock()
+
+	fmt.Fprintf(w, "*%d\r\n", len(replies))
+	for _, reply := range replies {
+		reply(w)
+	}
+}
+
+// run applies a command; c.mu must be held. It returns the writer of the
+// reply.
+func (c *Cluster) run(args []string) func(*bufio.Writer) {
+	name := strings.ToUpper(args[0])
+
+	slot := -1
+	for _, key := range keys(args) {
+		s := Slot(key)
+		if slot >= 0 && s != slot {
+			return errorReply("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+		slot = s
+	}
+
+	switch {
+	case name == "PING":
+		return simpleReply("PONG")
+	case name == "CLUSTER" && len(args) == 2 && strings.EqualFold(args[1], "SLOTS"):
+		host, port, _ := net.SplitHostPort(c.Addr())
+		return func(w *bufio.Writer) {
+			fmt.Fprintf(w, "*1\r\n*3\r\n:0\r\n:16383\r\n*3\r\n")
+			writeBulk(w, host)
+			fmt.Fprintf(w, ":%s\r\n", port)
+			writeBulk(w, "cachetest")
+		}
+	case name == "GET" && len(args) == 2:
+		value, ok := c.values[args[1]]
+		if !ok {
+			return func(w *bufio.Writer) { w.WriteString("$-1\r\n") }
+		}
+		return bulkReply(value)
+	case name == "SET" && len(args) >= 3:
+		c.values[args[1]] = args[2]
+		return simpleReply("OK")
+	case name == "DEL" && len(args) >= 2:
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := c.values[key]; ok {
+				delete(c.values, key)
+				deleted++
+			}
+		}
+		return intReply(int64(deleted))
+	case name == "INCR" && len(args) == 2:
+		n, err := strconv.ParseInt(c.values[args[1]], 10, 64)
+		if err != nil && c.values[args[1]] != "" {
+			return errorReply("ERR value is not an integer or out of range")
+		}
+		n++
+		c.values[args[1]] = strconv.FormatInt(n, 10)
+		return intReply(n)
+	case name == "SCAN" && len(args) >= 2:
+		// Everything is returned at once; the pattern is matched like a path
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		var matched []string
+		for key := range c.values {
+			if ok, _ := path.Match(pattern, key); ok {
+				matched = append(matched, key)
+			}
+		}
+		return func(w *bufio.Writer) {
+			fmt.Fprintf(w, "*2\r\n")
+			writeBulk(w, "0")
+			fmt.Fprintf(w, "*%d\r\n", len(matched))
+			for _, key := range matched {
+				writeBulk(w, key)
+			}
+		}
+	case name == "FLUSHDB":
+		c.values = map[string]string{}
+		return simpleReply("OK")
+	default:
+		return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+}
+
+// keys returns the keys a command touches.
+func keys(args []string) []string {
+	switch strings.ToUpper(args[0]) {
+	case "GET", "SET", "INCR":
+		if len(args) > 1 {
+			return args[1:2]
+		}
+	case "DEL":
+		return args[1:]
+	}
+	return nil
+}
+
+// Slot returns the cluster hash slot of key, honouring {hash tags}.
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % 16384)
+}
+
+// crc16 is the CRC16-CCITT (XModem) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// readCommand reads a RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[0] != '*' {
+		return nil, fmt.Errorf("cachetest: unexpected %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("cachetest: unexpected %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 2 || line[0] != '$' {
+			return nil, fmt.Errorf("cachetest: unexpected %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("cachetest: unexpected %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line without its CRLF.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func simpleReply(s string) func(*bufio.Writer) {
+	return func(w *bufio.Writer) { writeSimple(w, s) }
+}
+
+func errorReply(s string) func(*bufio.Writer) {
+	return func(w *bufio.Writer) { writeError(w, s) }
+}
+
+func bulkReply(s string) func(*bufio.Writer) {
+	return func(w *bufio.Writer) { writeBulk(w, s) }
+}
+
+func intReply(n int64) func(*bufio.Writer) {
+	return func(w *bufio.Writer) { fmt.Fprintf(w, ":%d\r\n", n) }
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "-%s\r\n", s)
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
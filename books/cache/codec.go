@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding names accepted by the cache.encoding config option.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+	EncodingGob     = "gob"
+)
+
+// headerMagic marks values written with a header. JSON documents never start
+// with this byte, so values without it are treated as legacy plain JSON.
+const headerMagic byte = 0xB0
+
+const (
+	encodingJSON byte = iota
+	encodingMsgpack
+	encodingGob
+)
+
+const (
+	compressionNone byte = iota
+	compressionGzip
+)
+
+// codec serializes cache values.
+type codec interface {
+	id() byte
+	marshal(v interface{}) ([]byte, error)
+	unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) id() byte { return encodingJSON }
+
+func (jsonCodec) marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) id() byte { return encodingMsgpack }
+
+func (msgpackCodec) marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) id() byte { return encodingGob }
+
+func (gobCodec) marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// codecByName returns the codec for a cache.encoding value.
+func codecByName(name string) (codec, error) {
+	switch name {
+	case "", EncodingJSON:
+		return jsonCodec{}, nil
+	case EncodingMsgpack:
+		return msgpackCodec{}, nil
+	case EncodingGob:
+		return gobCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache encoding %q", name)
+	}
+}
+
+// codecByID returns the codec recorded in a value header.
+func codecByID(id byte) (codec, error) {
+	switch id {
+	case encodingJSON:
+		return jsonCodec{}, nil
+	case encodingMsgpack:
+		return msgpackCodec{}, nil
+	case encodingGob:
+		return gobCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache encoding id %d", id)
+	}
+}
+
+// encode serializes v and compresses it when it is larger than threshold.
+// A threshold of 0 disables compression.
+func encode(c codec, threshold int, v interface{}) ([]byte, error) {
+	data, err := c.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := compressionNone
+	if threshold > 0 && len(data) > threshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+		compression = compressionGzip
+	}
+
+	out := make([]byte, 0, len(data)+2)
+	out = append(out, headerMagic|c.id(), compression)
+	return append(out, data...), nil
+}
+
+// decode reverses encode. Values without a header are decoded as JSON.
+func decode(data []byte, v interface{}) error {
+	if len(data) < 2 || data[0]&0xF0 != headerMagic {
+		return json.Unmarshal(data, v)
+	}
+
+	c, err := codecByID(data[0] & 0x0F)
+	if err != nil {
+		return err
+	}
+
+	payload := data[2:]
+	switch data[1] {
+	case compressionNone:
+	case compressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		if payload, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown cache compression id %d", data[1])
+	}
+
+	return c.unmarshal(payload, v)
+}
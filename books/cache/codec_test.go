@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testValue struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+func Test_Codec(t *testing.T) {
+	Convey("Cache value encoding", t, func() {
+		value := []testValue{{
+			ID:        1,
+			Title:     "Test Book",
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}}
+
+		for _, name := range []string{EncodingJSON, EncodingMsgpack, EncodingGob} {
+			name := name
+			Convey("Round trip with "+name, func() {
+				c, err := codecByName(name)
+				So(err, ShouldBeNil)
+
+				data, err := encode(c, 0, value)
+				So(err, ShouldBeNil)
+
+				var decoded []testValue
+				So(decode(data, &decoded), ShouldBeNil)
+				So(decoded, ShouldHaveLength, 1)
+				So(decoded[0].ID, ShouldEqual, value[0].ID)
+				So(decoded[0].Title, ShouldEqual, value[0].Title)
+				So(decoded[0].CreatedAt.Equal(value[0].CreatedAt), ShouldBeTrue)
+				So(decoded[0].DeletedAt, ShouldBeNil)
+			})
+		}
+
+		Convey("Compress values above the threshold", func() {
+			large := []testValue{{ID: 1, Title: strings.Repeat("a", 4096)}}
+
+			data, err := encode(jsonCodec{}, 1024, large)
+			So(err, ShouldBeNil)
+			So(data[1], ShouldEqual, compressionGzip)
+			So(len(data), ShouldBeLessThan, 1024)
+
+			var decoded []testValue
+			So(decode(data, &decoded), ShouldBeNil)
+			So(decoded[0].Title, ShouldEqual, large[0].Title)
+		})
+
+		Convey("Decode legacy values without a header as JSON", func() {
+			var decoded []testValue
+			So(decode([]byte(`[{"id":1,"title":"Test Book"}]`), &decoded), ShouldBeNil)
+			So(decoded[0].Title, ShouldEqual, "Test Book")
+		})
+
+		Convey("Reject unknown encodings", func() {
+			_, err := codecByName("xml")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
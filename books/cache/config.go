@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// Redis deployment modes accepted by the redis.mode config option.
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
+// RedisConfig holds the Redis connection options.
+type RedisConfig struct {
+	// Mode is one of single, sentinel or cluster.
+	Mode string
+	// DSN is a host:port address or a redis:// or rediss:// URL. It is only
+	// used in single mode when Addrs is empty.
+	DSN string
+	// Addrs are the cluster seed nodes or the sentinel addresses.
+	Addrs []string
+	// MasterName is the sentinel master name.
+	MasterName string
+
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int
+
+	PoolSize     int
+	MinIdleConns int
+
+	TLS TLSConfig
+}
+
+// TLSConfig holds the Redis TLS options.
+type TLSConfig struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// LoadRedisConfig reads the redis section of the config and validates it.
+func LoadRedisConfig() (RedisConfig, error) {
+	cfg := RedisConfig{
+		Mode:             viper.GetString("redis.mode"),
+		DSN:              viper.GetString("redis.dsn"),
+		Addrs:            viper.GetStringSlice("redis.addrs"),
+		MasterName:       viper.GetString("redis.master_name"),
+		Username:         viper.GetString("redis.username"),
+		Password:         viper.GetString("redis.password"),
+		SentinelUsername: viper.GetString("redis.sentinel_username"),
+		SentinelPassword: viper.GetString("redis.sentinel_password"),
+		DB:               viper.GetInt("redis.db"),
+		PoolSize:         viper.GetInt("redis.pool_size"),
+		MinIdleConns:     viper.GetInt("redis.min_idle_conns"),
+		TLS: TLSConfig{
+			Enabled:            viper.GetBool("redis.tls.enabled"),
+			CAFile:             viper.GetString("redis.tls.ca_file"),
+			CertFile:           viper.GetString("redis.tls.cert_file"),
+			KeyFile:            viper.GetString("redis.tls.key_file"),
+			ServerName:         viper.GetString("redis.tls.server_name"),
+			InsecureSkipVerify: viper.GetBool("redis.tls.insecure_skip_verify"),
+		},
+	}
+
+	// Environment variables arrive as a single comma separated string
+	if len(cfg.Addrs) == 1 && strings.Contains(cfg.Addrs[0], ",") {
+		cfg.Addrs = strings.Split(cfg.Addrs[0], ",")
+	}
+
+	if cfg.Mode == "" {
+		switch {
+		case cfg.MasterName != "":
+			cfg.Mode = ModeSentinel
+		case len(cfg.Addrs) > 1:
+			cfg.Mode = ModeCluster
+		default:
+			cfg.Mode = ModeSingle
+		}
+	}
+
+	if cfg.DSN == "" && len(cfg.Addrs) == 0 {
+		cfg.DSN = "127.0.0.1:6379"
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return RedisConfig{}, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the options describe a usable Redis deployment.
+func (cfg RedisConfig) Validate() error {
+	switch cfg.Mode {
+	case ModeSingle:
+		if cfg.DSN == "" && len(cfg.Addrs) != 1 {
+			return fmt.Errorf("redis: single mode requires redis.dsn or exactly one address in redis.addrs")
+		}
+	case ModeSentinel:
+		if cfg.MasterName == "" {
+			return fmt.Errorf("redis: sentinel mode requires redis.master_name")
+		}
+		if len(cfg.Addrs) == 0 {
+			return fmt.Errorf("redis: sentinel mode requires the sentinel addresses in redis.addrs")
+		}
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return fmt.Errorf("redis: cluster mode requires the seed nodes in redis.addrs")
+		}
+		if cfg.DB != 0 {
+			return fmt.Errorf("redis: cluster mode only supports redis.db 0")
+		}
+	default:
+		return fmt.Errorf("redis: unknown mode %q, expected single, sentinel or cluster", cfg.Mode)
+	}
+
+	if cfg.DB < 0 {
+		return fmt.Errorf("redis: redis.db must not be negative")
+	}
+	if cfg.PoolSize < 0 || cfg.MinIdleConns < 0 {
+		return fmt.Errorf("redis: pool sizes must not be negative")
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("redis: redis.tls.cert_file and redis.tls.key_file must be set together")
+	}
+
+	if _, err := cfg.tlsConfig(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UniversalOptions builds the go-redis client options.
+func (cfg RedisConfig) UniversalOptions() (*redis.UniversalOptions, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.DB,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		TLSConfig:        tlsConfig,
+		IsClusterMode:    cfg.Mode == ModeCluster,
+	}
+
+	if cfg.Mode == ModeSingle && len(cfg.Addrs) == 0 {
+		dsn := cfg.DSN
+		if !strings.Contains(dsn, "://") {
+			dsn = "redis://" + dsn
+		}
+
+		parsed, err := redis.ParseURL(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid redis.dsn: %v", err)
+		}
+
+		// Explicit options take precedence over the ones in the URL
+		opts.Addrs = []string{parsed.Addr}
+		if opts.Username == "" {
+			opts.Username = parsed.Username
+		}
+		if opts.Password == "" {
+			opts.Password = parsed.Password
+		}
+		if opts.DB == 0 {
+			opts.DB = parsed.DB
+		}
+		if opts.TLSConfig == nil {
+			opts.TLSConfig = parsed.TLSConfig
+		}
+	}
+
+	return opts, nil
+}
+
+// tlsConfig builds the TLS configuration, or returns nil when TLS is disabled.
+func (cfg RedisConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.TLS.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify, //nolint:gosec // opt-in for test environments
+	}
+
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: failed to read redis.tls.ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis: no certificates found in redis.tls.ca_file %s", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// missedInvalidations remembers the invalidations that could not be applied,
+// because Redis was down, the breaker was open or the write queue was full.
+// They are replayed before Redis is used again, so that entries written
+// before an outage are not served once it ends. The zero value is ready to
+// use.
+type missedInvalidations struct {
+	// pending is set while keys or counters are waiting to be applied.
+	pending atomic.Bool
+
+	mu       sync.Mutex
+	keys     map[string]struct{}
+	counters map[string]struct{}
+}
+
+// add records keys to delete and counters to increment. Repeated keys and
+// counters are only applied once.
+func (m *missedInvalidations) add(keys, counters []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keys == nil {
+		m.keys = make(map[string]struct{})
+		m.counters = make(map[string]struct{})
+	}
+	for _, key := range keys {
+		m.keys[key] = struct{}{}
+	}
+	for _, counter := range counters {
+		m.counters[counter] = struct{}{}
+	}
+	m.pending.Store(true)
+}
+
+// apply deletes the missed keys and increments the missed counters. Calls
+// made meanwhile wait for it. On failure everything is kept for the next
+// call.
+func (m *missedInvalidations) apply(ctx context.Context, client redis.Cmdable) error {
+	if !m.pending.Load() {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.pending.Load() {
+		// Applied by a concurrent call
+		return nil
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key := range m.keys {
+			pipe.Del(ctx, key)
+		}
+		for counter := range m.counters {
+			pipe.Incr(ctx, counter)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.keys, m.counters = nil, nil
+	m.pending.Store(false)
+	return nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/books/books/cache/cachetest"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_MissedInvalidations(t *testing.T) {
+	Convey("Invalidations missed during an outage", t, func() {
+		server := cachetest.NewCluster()
+		defer server.Close()
+
+		settings := map[string]interface{}{
+			"redis.mode":                    ModeSingle,
+			"redis.dsn":                     server.Addr(),
+			"cache.breaker.threshold":       1,
+			"cache.breaker.cooldown":        "10ms",
+			"cache.write.retries":           0,
+			"cache.write.sync_invalidation": true,
+		}
+		for key, value := range settings {
+			viper.Set(key, value)
+		}
+		defer func() {
+			for key := range settings {
+				viper.Set(key, nil)
+			}
+		}()
+
+		ctx := context.Background()
+		c, err := NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		So(c.Set(ctx, "books:books:getbyid:1", "before", time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getbyid:2", "before", time.Minute), ShouldBeNil)
+
+		server.SetDown(true)
+		// Fails against Redis, then is dropped by the open breaker
+		So(c.Invalidate(ctx, []string{"books:books:getbyid:1"}, []string{"books:books:getall:version"}), ShouldNotBeNil)
+		So(c.Invalidate(ctx, []string{"books:books:getbyid:2"}, []string{"books:books:getall:version"}), ShouldEqual, ErrUnavailable)
+		server.SetDown(false)
+		time.Sleep(20 * time.Millisecond)
+
+		Convey("Are applied before the cache is read again", func() {
+			var value string
+			So(c.Get(ctx, "books:books:getbyid:1", &value), ShouldEqual, ErrCacheMiss)
+			So(c.Get(ctx, "books:books:getbyid:2", &value), ShouldEqual, ErrCacheMiss)
+
+			version, err := c.Counter(ctx, "books:books:getall:version")
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 1)
+		})
+
+		Convey("Are kept while Redis is still down", func() {
+			server.SetDown(true)
+			var value string
+			So(c.Get(ctx, "books:books:getbyid:1", &value), ShouldNotBeNil)
+			server.SetDown(false)
+			time.Sleep(20 * time.Millisecond)
+
+			So(c.Get(ctx, "books:books:getbyid:1", &value), ShouldEqual, ErrCacheMiss)
+			_, cached := server.Get("books:books:getbyid:2")
+			So(cached, ShouldBeFalse)
+		})
+	})
+}
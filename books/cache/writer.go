@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriterClosed is returned when a write is queued after Drain was called.
+var ErrWriterClosed = errors.New("cache writer closed")
+
+// ErrQueueFull is returned when the write-behind queue has no free slots.
+var ErrQueueFull = errors.New("cache write queue full")
+
+// WriterConfig configures the write-behind worker.
+type WriterConfig struct {
+	// Workers is the number of goroutines applying queued writes.
+	Workers int
+	// QueueSize is the maximum number of pending writes.
+	QueueSize int
+	// Retries is the number of extra attempts for a failed write.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled on each attempt.
+	RetryBackoff time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+}
+
+// Stats holds cache write counters.
+type Stats struct {
+	// Queued is the number of writes accepted by the write-behind queue.
+	Queued uint64 `json:"queued"`
+	// Written is the number of writes applied successfully.
+	Written uint64 `json:"written"`
+	// Retried is the number of retry attempts.
+	Retried uint64 `json:"retried"`
+	// Failed is the number of writes that failed after all retries.
+	Failed uint64 `json:"failed"`
+	// Dropped is the number of writes rejected because the queue was full,
+	// the writer was closed or the cache was unavailable.
+	Dropped uint64 `json:"dropped"`
+}
+
+// writeOp is a single cache write.
+type writeOp struct {
+	// ctx carries the values, such as the request ID, of the context that
+	// queued the write. It is not cancelled with the request.
+	ctx  context.Context
+	name string
+	key  string
+	fn   func(ctx context.Context) error
+	// lost, if set, is called when the write is dropped or fails.
+	lost func()
+}
+
+// giveUp calls the lost callback of op, if any.
+func (op writeOp) giveUp() {
+	if op.lost != nil {
+		op.lost()
+	}
+}
+
+// writer applies cache writes in the background with bounded concurrency.
+type writer struct {
+	cfg   WriterConfig
+	queue chan writeOp
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	queued  atomic.Uint64
+	written atomic.Uint64
+	retried atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// newWriter starts the write-behind workers.
+func newWriter(cfg WriterConfig) *writer {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+
+	w := &writer{
+		cfg:   cfg,
+		queue: make(chan writeOp, cfg.QueueSize),
+	}
+
+	w.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go func() {
+			defer w.wg.Done()
+			for op := range w.queue {
+				_ = w.do(op.ctx, op)
+			}
+		}()
+	}
+
+	return w
+}
+
+// enqueue queues op without blocking.
+func (w *writer) enqueue(op writeOp) error {
+	if op.ctx == nil {
+		op.ctx = context.Background()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.dropped.Add(1)
+		op.giveUp()
+		return ErrWriterClosed
+	}
+
+	select {
+	case w.queue <- op:
+		w.queued.Add(1)
+		return nil
+	default:
+		w.dropped.Add(1)
+		op.giveUp()
+		slog.WarnContext(op.ctx, "cache write queue full, dropping write", "op", op.name, "key", op.key)
+		return ErrQueueFull
+	}
+}
+
+// do applies op, retrying with exponential backoff on failure. Writes are
+// dropped without retrying while the cache is unavailable, since retries
+// would only hold up the queue until the breaker closes.
+func (w *writer) do(ctx context.Context, op writeOp) error {
+	backoff := w.cfg.RetryBackoff
+
+	var err error
+	for attempt := 0; attempt <= w.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			w.retried.Add(1)
+			select {
+			case <-ctx.Done():
+				w.failed.Add(1)
+				op.giveUp()
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+		err = op.fn(attemptCtx)
+		cancel()
+		if err == nil {
+			w.written.Add(1)
+			return nil
+		}
+		if errors.Is(err, ErrUnavailable) {
+			w.dropped.Add(1)
+			op.giveUp()
+			slog.DebugContext(ctx, "cache unavailable, dropping write", "op", op.name, "key", op.key)
+			return err
+		}
+	}
+
+	w.failed.Add(1)
+	op.giveUp()
+	slog.WarnContext(ctx, "cache write failed", "op", op.name, "key", op.key, "error", err.Error())
+	return err
+}
+
+// drain stops accepting writes and waits for queued writes to be applied.
+func (w *writer) drain(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stats returns a snapshot of the write counters.
+func (w *writer) stats() Stats {
+	return Stats{
+		Queued:  w.queued.Load(),
+		Written: w.written.Load(),
+		Retried: w.retried.Load(),
+		Failed:  w.failed.Load(),
+		Dropped: w.dropped.Load(),
+	}
+}
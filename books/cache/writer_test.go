@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Writer(t *testing.T) {
+	Convey("Cache write-behind worker", t, func() {
+		w := newWriter(WriterConfig{
+			Workers:      2,
+			QueueSize:    8,
+			Retries:      2,
+			RetryBackoff: time.Millisecond,
+			Timeout:      time.Second,
+		})
+
+		Convey("Apply queued writes before drain returns", func() {
+			var applied atomic.Int32
+			for i := 0; i < 5; i++ {
+				err := w.enqueue(writeOp{name: "set", key: "k", fn: func(ctx context.Context) error {
+					applied.Add(1)
+					return nil
+				}})
+				So(err, ShouldBeNil)
+			}
+
+			So(w.drain(context.Background()), ShouldBeNil)
+			So(applied.Load(), ShouldEqual, 5)
+			So(w.stats().Written, ShouldEqual, 5)
+		})
+
+		Convey("Retry failed writes and count failures", func() {
+			var attempts atomic.Int32
+			err := w.do(context.Background(), writeOp{name: "delete", key: "k", fn: func(ctx context.Context) error {
+				attempts.Add(1)
+				return errors.New("connection reset")
+			}})
+
+			So(err, ShouldNotBeNil)
+			So(attempts.Load(), ShouldEqual, 3)
+			So(w.stats().Retried, ShouldEqual, 2)
+			So(w.stats().Failed, ShouldEqual, 1)
+			So(w.drain(context.Background()), ShouldBeNil)
+		})
+
+		Convey("Drop writes without retrying while the cache is unavailable", func() {
+			var attempts atomic.Int32
+			err := w.do(context.Background(), writeOp{name: "set", key: "k", fn: func(ctx context.Context) error {
+				attempts.Add(1)
+				return ErrUnavailable
+			}})
+
+			So(err, ShouldEqual, ErrUnavailable)
+			So(attempts.Load(), ShouldEqual, 1)
+			So(w.stats().Retried, ShouldEqual, 0)
+			So(w.stats().Dropped, ShouldEqual, 1)
+			So(w.drain(context.Background()), ShouldBeNil)
+		})
+
+		Convey("Report the writes that are given up", func() {
+			var lost atomic.Int32
+			fail := func(err error) writeOp {
+				return writeOp{
+					name: "invalidate",
+					key:  "k",
+					fn:   func(ctx context.Context) error { return err },
+					lost: func() { lost.Add(1) },
+				}
+			}
+
+			So(w.do(context.Background(), fail(errors.New("connection reset"))), ShouldNotBeNil)
+			So(w.do(context.Background(), fail(ErrUnavailable)), ShouldNotBeNil)
+			So(w.do(context.Background(), fail(nil)), ShouldBeNil)
+			So(w.drain(context.Background()), ShouldBeNil)
+			So(w.enqueue(fail(nil)), ShouldEqual, ErrWriterClosed)
+			So(lost.Load(), ShouldEqual, 3)
+		})
+
+		Convey("Reject writes after drain", func() {
+			So(w.drain(context.Background()), ShouldBeNil)
+			err := w.enqueue(writeOp{name: "set", key: "k", fn: func(ctx context.Context) error { return nil }})
+			So(err, ShouldEqual, ErrWriterClosed)
+			So(w.stats().Dropped, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package books
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// readPinKey is the context key of the read pin.
+type readPinKey struct{}
+
+// readPin tracks where the reads of a request go.
+type readPin struct {
+	// primary sends the reads to the primary database.
+	primary atomic.Bool
+	// wrote is set once the request has written.
+	wrote atomic.Bool
+}
+
+// WithReadPin returns a context that tracks whether reads must go to the
+// primary database. Repositories that route reads to replicas pin the context
+// after a write, so that later reads in the same request see that write
+// instead of a lagging replica.
+func WithReadPin(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(readPinKey{}).(*readPin); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, readPinKey{}, &readPin{})
+}
+
+// PinPrimary records a write and pins the remaining reads in ctx to the
+// primary database. It does nothing if ctx was not created by WithReadPin.
+func PinPrimary(ctx context.Context) {
+	if pin, ok := ctx.Value(readPinKey{}).(*readPin); ok {
+		pin.wrote.Store(true)
+		pin.primary.Store(true)
+	}
+}
+
+// PinReads pins the reads in ctx to the primary database without recording a
+// write, e.g. for a client that wrote shortly before. It does nothing if ctx
+// was not created by WithReadPin.
+func PinReads(ctx context.Context) {
+	if pin, ok := ctx.Value(readPinKey{}).(*readPin); ok {
+		pin.primary.Store(true)
+	}
+}
+
+// PrimaryPinned reports whether reads in ctx must go to the primary database.
+func PrimaryPinned(ctx context.Context) bool {
+	pin, ok := ctx.Value(readPinKey{}).(*readPin)
+	return ok && pin.primary.Load()
+}
+
+// Wrote reports whether PinPrimary was called with ctx.
+func Wrote(ctx context.Context) bool {
+	pin, ok := ctx.Value(readPinKey{}).(*readPin)
+	return ok && pin.wrote.Load()
+}
+
+// replicaReadKey is the context key of the replica read flag.
+type replicaReadKey struct{}
+
+// trackReplicaReads returns a context in which ReadFromReplica sets the
+// returned flag.
+func trackReplicaReads(ctx context.Context) (context.Context, *atomic.Bool) {
+	fromReplica := &atomic.Bool{}
+	return context.WithValue(ctx, replicaReadKey{}, fromReplica), fromReplica
+}
+
+// ReadFromReplica records that a read in ctx was answered by a replica, which
+// may lag behind the primary. The service does not cache such results: they
+// may predate a write whose invalidation already ran.
+func ReadFromReplica(ctx context.Context) {
+	if fromReplica, ok := ctx.Value(replicaReadKey{}).(*atomic.Bool); ok {
+		fromReplica.Store(true)
+	}
+}
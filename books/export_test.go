@@ -0,0 +1,10 @@
+package books
+
+// Unexported functions under test.
+var (
+	TopAuthors     = topAuthors
+	GetAllCacheKey = getAllCacheKey
+)
+
+// ListVersionKey is the counter versioning the cached list pages.
+const ListVersionKey = listVersionKey
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/books/auth"
+)
+
+// APIKeyRepository is a thread-safe in-memory implementation of
+// auth.KeyRepository.
+type APIKeyRepository struct {
+	mu   sync.RWMutex
+	keys []auth.APIKey
+}
+
+// NewAPIKeyRepository returns a new, empty APIKeyRepository.
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{}
+}
+
+// Create stores a new API key and returns it with its ID.
+func (r *APIKeyRepository) Create(ctx context.Context, key auth.APIKey) (*auth.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.keys {
+		if stored.Prefix == key.Prefix {
+			return nil, auth.ErrKeyAlreadyExists
+		}
+	}
+
+	key.ID = int64(len(r.keys)) + 1
+	key.CreatedAt = key.CreatedAt.UTC().Truncate(time.Microsecond)
+	key.RevokedAt = nil
+	r.keys = append(r.keys, key)
+
+	return &key, nil
+}
+
+// GetByPrefix retrieves an API key, revoked or not, by its prefix.
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			return &key, nil
+		}
+	}
+	return nil, auth.ErrKeyNotFound
+}
+
+// List retrieves every API key ordered by ID.
+func (r *APIKeyRepository) List(ctx context.Context) ([]auth.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]auth.APIKey{}, r.keys...), nil
+}
+
+// Revoke sets revokedAt on a key that is not revoked yet.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// IDs are assigned in order and keys are never removed
+	if id < 1 || id > int64(len(r.keys)) {
+		return auth.ErrKeyNotFound
+	}
+	if r.keys[id-1].Revoked() {
+		return auth.ErrKeyRevoked
+	}
+	revokedAt := at.UTC().Truncate(time.Microsecond)
+	r.keys[id-1].RevokedAt = &revokedAt
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/books/books"
+)
+
+// BookRepository is a thread-safe in-memory implementation of
+// books.BookRepository. It mirrors mysql.BookRepository: deleted books are
+// kept and still count towards the (title, author, isbn) uniqueness, text
+// comparisons are case-insensitive like MySQL's default collation, and
+// timestamps are stored with microsecond precision.
+type BookRepository struct {
+	mu     sync.RWMutex
+	books  []books.Book
+	nextID int64
+}
+
+// NewBookRepository returns a new, empty BookRepository.
+func NewBookRepository() *BookRepository {
+	return &BookRepository{nextID: 1}
+}
+
+// Create creates a new book.
+func (r *BookRepository) Create(ctx context.Context, book books.Book) (*books.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts(0, book) {
+		return nil, books.ErrBookAlreadyExists
+	}
+
+	book.ID = r.nextID
+	r.nextID++
+
+	stored := book
+	stored.DeletedAt = nil
+	r.books = append(r.books, normalize(stored))
+
+	return &book, nil
+}
+
+// GetByID retrieves a book by its ID.
+func (r *BookRepository) GetByID(ctx context.Context, id int64) (*books.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.find(id)
+	if i < 0 {
+		return nil, books.ErrBookNotFound
+	}
+
+	book := r.books[i]
+	return &book, nil
+}
+
+// GetAll retrieves all books (excluding deleted ones).
+// If author is provided, filters books by that author.
+// limit and offset are used for pagination. If limit is 0, no limit is applied.
+func (r *BookRepository) GetAll(ctx context.Context, author *string, limit, offset int) ([]books.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Books are appended in ID order, so no sorting is needed
+	bookList := []books.Book{}
+	for _, book := range r.books {
+		if book.DeletedAt != nil {
+			continue
+		}
+		if author != nil && *author != "" && !strings.EqualFold(book.Author, *author) {
+			continue
+		}
+		bookList = append(bookList, book)
+	}
+
+	if limit > 0 {
+		if offset > len(bookList) {
+			offset = len(bookList)
+		}
+		bookList = bookList[offset:]
+		if limit < len(bookList) {
+			bookList = bookList[:limit]
+		}
+	}
+
+	return bookList, nil
+}
+
+// Update updates an existing book.
+func (r *BookRepository) Update(ctx context.Context, id int64, book books.Book) (*books.Book, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(id)
+	if i < 0 {
+		return nil, books.ErrBookNotFound
+	}
+	if r.conflicts(id, book) {
+		return nil, books.ErrBookAlreadyExists
+	}
+
+	updatedBook := r.books[i]
+	updatedBook.Title = book.Title
+	updatedBook.Author = book.Author
+	updatedBook.ISBN = book.ISBN
+	updatedBook.Description = book.Description
+	updatedBook.PublishedAt = book.PublishedAt
+	updatedBook.UpdatedAt = book.UpdatedAt
+	r.books[i] = normalize(updatedBook)
+
+	return &updatedBook, nil
+}
+
+// Delete soft deletes a book by setting deletedAt.
+func (r *BookRepository) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(id)
+	if i < 0 {
+		return books.ErrBookNotFound
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	r.books[i].DeletedAt = &now
+
+	return nil
+}
+
+// reset removes all books and restarts ID generation.
+func (r *BookRepository) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.books = nil
+	r.nextID = 1
+}
+
+// bookState is a copy of the repository contents.
+type bookState struct {
+	books  []books.Book
+	nextID int64
+}
+
+// snapshot returns a copy of the repository contents.
+func (r *BookRepository) snapshot() bookState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return bookState{books: append([]books.Book(nil), r.books...), nextID: r.nextID}
+}
+
+// restore replaces the repository contents with a snapshot.
+func (r *BookRepository) restore(state bookState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.books = state.books
+	r.nextID = state.nextID
+}
+
+// find returns the index of the non-deleted book with the given ID, or -1.
+func (r *BookRepository) find(id int64) int {
+	for i, book := range r.books {
+		if book.ID == id && book.DeletedAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// conflicts reports whether another book (deleted or not) has the same
+// title, author and ISBN, mirroring the UQ_books_title_author_isbn key.
+func (r *BookRepository) conflicts(id int64, book books.Book) bool {
+	for _, existing := range r.books {
+		if existing.ID == id {
+			continue
+		}
+		if strings.EqualFold(existing.Title, book.Title) &&
+			strings.EqualFold(existing.Author, book.Author) &&
+			strings.EqualFold(existing.ISBN, book.ISBN) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize converts timestamps to what MySQL would return: UTC with
+// microsecond precision.
+func normalize(book books.Book) books.Book {
+	book.PublishedAt = book.PublishedAt.UTC().Truncate(time.Microsecond)
+	book.CreatedAt = book.CreatedAt.UTC().Truncate(time.Microsecond)
+	book.UpdatedAt = book.UpdatedAt.UTC().Truncate(time.Microsecond)
+	return book
+}
@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/repotest"
+)
+
+func Test_BookRepository(t *testing.T) {
+	repotest.Run(t, func() books.RepositoryProvider {
+		return NewRepositoryProvider()
+	})
+}
+
+func Test_APIKeyRepository(t *testing.T) {
+	repotest.RunKeys(t, func() auth.KeyRepository {
+		return NewAPIKeyRepository()
+	})
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/books/books"
+)
+
+// RepositoryProvider manages all in-memory repositories.
+// Repositories returned by the same provider share their data.
+type RepositoryProvider struct {
+	book *BookRepository
+	// txMu serializes transactions; inTx is set on providers passed to
+	// WithTx callbacks.
+	txMu *sync.Mutex
+	inTx bool
+}
+
+// NewRepositoryProvider returns a new, empty RepositoryProvider.
+func NewRepositoryProvider() *RepositoryProvider {
+	return &RepositoryProvider{book: NewBookRepository(), txMu: &sync.Mutex{}}
+}
+
+// Book returns the BookRepository.
+func (rp *RepositoryProvider) Book() books.BookRepository {
+	return rp.book
+}
+
+// WithTx runs fn and restores the data as it was before if fn fails.
+// Transactions run one at a time, but writes outside of a transaction are not
+// isolated from them: a rollback restores a snapshot of the whole store, so it
+// also undoes the writes other goroutines made while fn ran. That is fine for
+// the tests and demos this provider is meant for, but not for concurrent
+// writers that rely on rollbacks.
+func (rp *RepositoryProvider) WithTx(ctx context.Context, fn func(books.RepositoryProvider) error) (err error) {
+	if rp.inTx {
+		return fn(rp)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rp.txMu.Lock()
+	defer rp.txMu.Unlock()
+
+	saved := rp.book.snapshot()
+	defer func() {
+		if p := recover(); p != nil {
+			rp.book.restore(saved)
+			panic(p)
+		}
+		if err != nil {
+			rp.book.restore(saved)
+		}
+	}()
+
+	return fn(&RepositoryProvider{book: rp.book, txMu: rp.txMu, inTx: true})
+}
+
+// Reset removes all data and restarts ID generation.
+func (rp *RepositoryProvider) Reset() {
+	rp.book.reset()
+}
@@ -13,17 +13,19 @@ import (
 
 // BookRepository contains all methods to access the books table.
 type BookRepository struct {
-	db *sqlx.DB
+	db       sqlx.ExtContext
+	replicas *Replicas
 }
 
-// NewBookRepository returns a new BookRepository.
-func NewBookRepository(db *sqlx.DB) *BookRepository {
+// NewBookRepository returns a new BookRepository. db is either a *sqlx.DB or
+// a *sqlx.Tx.
+func NewBookRepository(db sqlx.ExtContext) *BookRepository {
 	return &BookRepository{db: db}
 }
 
 // Create creates a new book in the database.
 func (r *BookRepository) Create(ctx context.Context, book books.Book) (*books.Book, error) {
-	result, err := r.db.NamedExecContext(ctx, `
+	result, err := sqlx.NamedExecContext(ctx, r.db, `
 		INSERT INTO books (
 			title,
 			author,
@@ -64,14 +66,41 @@ func (r *BookRepository) Create(ctx context.Context, book books.Book) (*books.Bo
 	}
 
 	book.ID = id
+	books.PinPrimary(ctx)
 	return &book, nil
 }
 
 // GetByID retrieves a book by its ID.
 func (r *BookRepository) GetByID(ctx context.Context, id int64) (*books.Book, error) {
+	var book *books.Book
+	err := retry(ctx, r.db, func() error {
+		var err error
+		book, err = getByID(ctx, r.reader(ctx), id, "")
+		return err
+	})
+	return book, err
+}
+
+// reader returns where to send a read: the transaction if there is one, the
+// primary if ctx is pinned to it or no replica is healthy, and otherwise the
+// next replica. Replica reads are recorded in ctx.
+func (r *BookRepository) reader(ctx context.Context) sqlx.QueryerContext {
+	if _, ok := r.db.(*sqlx.DB); !ok || books.PrimaryPinned(ctx) {
+		return r.db
+	}
+	if replica := r.replicas.pick(); replica != nil {
+		books.ReadFromReplica(ctx)
+		return replica
+	}
+	return r.db
+}
+
+// getByID retrieves a non-deleted book by its ID. lock is appended to the
+// query, e.g. FOR UPDATE.
+func getByID(ctx context.Context, q sqlx.QueryerContext, id int64, lock string) (*books.Book, error) {
 	var book books.Book
-	err := r.db.GetContext(ctx, &book, `
-		SELECT 
+	err := sqlx.GetContext(ctx, q, &book, `
+		SELECT
 			id,
 			title,
 			author,
@@ -84,7 +113,7 @@ func (r *BookRepository) GetByID(ctx context.Context, id int64) (*books.Book, er
 		FROM books
 		WHERE id = ?
 		AND deletedAt IS NULL
-	`, id)
+	`+lock, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, books.ErrBookNotFound
@@ -114,109 +143,130 @@ func (r *BookRepository) GetAll(ctx context.Context, author *string, limit, offs
 		WHERE deletedAt IS NULL
 	`
 	args := []interface{}{}
-	
+
 	if author != nil && *author != "" {
 		query += ` AND author = ?`
 		args = append(args, *author)
 	}
-	
+
 	query += ` ORDER BY id ASC`
-	
+
 	if limit > 0 {
 		query += ` LIMIT ?`
 		args = append(args, limit)
-		
+
 		if offset > 0 {
 			query += ` OFFSET ?`
 			args = append(args, offset)
 		}
 	}
-		
-	err := r.db.SelectContext(ctx, &bookList, query, args...)
+
+	err := retry(ctx, r.db, func() error {
+		return sqlx.SelectContext(ctx, r.reader(ctx), &bookList, query, args...)
+	})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	
+
 	// Ensure we always return a non-nil slice (empty slice instead of nil)
 	// This ensures JSON serialization produces [] instead of null
 	if bookList == nil {
 		bookList = []books.Book{}
 	}
-	
+
 	return bookList, nil
 }
 
-// Update updates an existing book.
+// Update updates an existing book. The row is locked while it is read and
+// written, so concurrent updates cannot interleave. The transaction is
+// retried on deadlocks and lock wait timeouts.
 func (r *BookRepository) Update(ctx context.Context, id int64, book books.Book) (*books.Book, error) {
-	// First check if book exists
-	existingBook, err := r.GetByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
+	var updatedBook books.Book
+	err := retry(ctx, r.db, func() error {
+		return transact(ctx, r.db, func(tx sqlx.ExtContext) error {
+			existingBook, err := getByID(ctx, tx, id, " FOR UPDATE")
+			if err != nil {
+				return err
+			}
 
-	// Update the book
-	_, err = r.db.NamedExecContext(ctx, `
-		UPDATE books
-		SET 
-			title = :title,
-			author = :author,
-			isbn = :isbn,
-			description = :description,
-			publishedAt = :publishedAt,
-			updatedAt = :updatedAt
-		WHERE id = :id
-		AND deletedAt IS NULL
-	`, map[string]interface{}{
-		"id":          id,
-		"title":       book.Title,
-		"author":      book.Author,
-		"isbn":        book.ISBN,
-		"description": book.Description,
-		"publishedAt": book.PublishedAt,
-		"updatedAt":   book.UpdatedAt,
+			_, err = sqlx.NamedExecContext(ctx, tx, `
+				UPDATE books
+				SET
+					title = :title,
+					author = :author,
+					isbn = :isbn,
+					description = :description,
+					publishedAt = :publishedAt,
+					updatedAt = :updatedAt
+				WHERE id = :id
+				AND deletedAt IS NULL
+			`, map[string]interface{}{
+				"id":          id,
+				"title":       book.Title,
+				"author":      book.Author,
+				"isbn":        book.ISBN,
+				"description": book.Description,
+				"publishedAt": book.PublishedAt,
+				"updatedAt":   book.UpdatedAt,
+			})
+			if err != nil {
+				// Check for duplicate key error (MySQL error code 1062)
+				if mysqlErr, ok := errors.Cause(err).(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+					return books.ErrBookAlreadyExists
+				}
+				return errors.WithStack(err)
+			}
+
+			updatedBook = *existingBook
+			updatedBook.Title = book.Title
+			updatedBook.Author = book.Author
+			updatedBook.ISBN = book.ISBN
+			updatedBook.Description = book.Description
+			updatedBook.PublishedAt = book.PublishedAt
+			updatedBook.UpdatedAt = book.UpdatedAt
+			return nil
+		})
 	})
 	if err != nil {
-		// Check for duplicate key error (MySQL error code 1062)
-		if mysqlErr, ok := errors.Cause(err).(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			return nil, books.ErrBookAlreadyExists
-		}
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
 
-	// Return updated book
-	updatedBook := *existingBook
-	updatedBook.Title = book.Title
-	updatedBook.Author = book.Author
-	updatedBook.ISBN = book.ISBN
-	updatedBook.Description = book.Description
-	updatedBook.PublishedAt = book.PublishedAt
-	updatedBook.UpdatedAt = book.UpdatedAt
-
+	books.PinPrimary(ctx)
 	return &updatedBook, nil
 }
 
-// Delete soft deletes a book by setting deletedAt.
+// Delete soft deletes a book by setting deletedAt. The existence check and
+// the update are a single conditional statement, retried on deadlocks and
+// lock wait timeouts.
 func (r *BookRepository) Delete(ctx context.Context, id int64) error {
-	// First check if book exists
-	_, err := r.GetByID(ctx, id)
-	if err != nil {
+	now := time.Now().UTC()
+	var result sql.Result
+	err := retry(ctx, r.db, func() error {
+		var err error
+		result, err = sqlx.NamedExecContext(ctx, r.db, `
+			UPDATE books
+			SET
+				deletedAt = :deletedAt
+			WHERE id = :id
+			AND deletedAt IS NULL
+		`, map[string]interface{}{
+			"id":        id,
+			"deletedAt": now,
+		})
 		return err
+	})
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	now := time.Now().UTC()
-	_, err = r.db.NamedExecContext(ctx, `
-		UPDATE books
-		SET 
-			deletedAt = :deletedAt
-		WHERE id = :id
-		AND deletedAt IS NULL
-	`, map[string]interface{}{
-		"id":        id,
-		"deletedAt": now,
-	})
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if affected == 0 {
+		return books.ErrBookNotFound
+	}
 
+	books.PinPrimary(ctx)
 	return nil
 }
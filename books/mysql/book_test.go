@@ -0,0 +1,41 @@
+package mysql_test
+
+import (
+	"testing"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/mysql"
+	"github.com/books/books/repotest"
+	"github.com/books/testdata"
+)
+
+func Test_BookRepository(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithDB()
+	defer suite.Close()
+
+	if suite.Driver() != "mysql" {
+		t.Skipf("test.db.driver is %s, the MySQL repository is not under test", suite.Driver())
+	}
+
+	repotest.Run(t, func() books.RepositoryProvider {
+		suite.ClearBooks()
+		return mysql.NewRepositoryProvider(suite.DB())
+	})
+}
+
+func Test_APIKeyRepository(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithDB()
+	defer suite.Close()
+
+	if suite.Driver() != "mysql" {
+		t.Skipf("test.db.driver is %s, the MySQL repository is not under test", suite.Driver())
+	}
+
+	repotest.RunKeys(t, func() auth.KeyRepository {
+		suite.ClearAPIKeys()
+		return mysql.NewAPIKeyRepository(suite.DB())
+	})
+}
@@ -0,0 +1,300 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// TLS modes, named after the mysql client's --ssl-mode.
+const (
+	TLSDisabled       = "disabled"
+	TLSPreferred      = "preferred"
+	TLSRequired       = "required"
+	TLSVerifyCA       = "verify_ca"
+	TLSVerifyIdentity = "verify_identity"
+)
+
+// Config holds the MySQL connection options.
+type Config struct {
+	Host string
+	Port int
+	// Socket is the path of a Unix socket. When set, Host and Port are ignored.
+	Socket   string
+	User     string
+	Password string
+	Database string
+
+	Charset   string
+	Collation string
+	// Params are extra driver parameters as name=value, e.g.
+	// time_zone='+00:00' or interpolateParams=true. They are a list rather
+	// than a map because viper lowercases map keys and the driver's own
+	// parameters are camelCase.
+	Params []string
+
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+
+	TLS  TLSConfig
+	Pool PoolConfig
+}
+
+// TLSConfig holds the TLS options. The certificate settings apply to the
+// required, verify_ca and verify_identity modes.
+type TLSConfig struct {
+	// Mode is one of disabled (the default), preferred, required, verify_ca
+	// or verify_identity.
+	Mode string
+	// CAFile is a PEM bundle used instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile are an optional client certificate.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name checked by verify_identity.
+	ServerName string
+}
+
+// PoolConfig holds the connection pool limits. Zero values keep the
+// database/sql defaults.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// LoadConfig reads the connection options under prefix, e.g. db or test.db.
+func LoadConfig(prefix string) Config {
+	key := func(name string) string {
+		return prefix + "." + name
+	}
+
+	return Config{
+		Host:           viper.GetString(key("host")),
+		Port:           viper.GetInt(key("port")),
+		Socket:         viper.GetString(key("socket")),
+		User:           viper.GetString(key("user")),
+		Password:       viper.GetString(key("password")),
+		Database:       viper.GetString(key("database")),
+		Charset:        viper.GetString(key("charset")),
+		Collation:      viper.GetString(key("collation")),
+		Params:         viper.GetStringSlice(key("params")),
+		ConnectTimeout: viper.GetDuration(key("timeouts.connect")),
+		ReadTimeout:    viper.GetDuration(key("timeouts.read")),
+		WriteTimeout:   viper.GetDuration(key("timeouts.write")),
+		TLS: TLSConfig{
+			Mode:       viper.GetString(key("tls.mode")),
+			CAFile:     viper.GetString(key("tls.ca_file")),
+			CertFile:   viper.GetString(key("tls.cert_file")),
+			KeyFile:    viper.GetString(key("tls.key_file")),
+			ServerName: viper.GetString(key("tls.server_name")),
+		},
+		Pool: PoolConfig{
+			MaxOpenConns:    viper.GetInt(key("pool.max_open_conns")),
+			MaxIdleConns:    viper.GetInt(key("pool.max_idle_conns")),
+			ConnMaxLifetime: viper.GetDuration(key("pool.conn_max_lifetime")),
+			ConnMaxIdleTime: viper.GetDuration(key("pool.conn_max_idle_time")),
+		},
+	}
+}
+
+// WithAddr returns a copy of the configuration for the server at host:port,
+// e.g. a read replica.
+func (cfg Config) WithAddr(host string, port int) Config {
+	cfg.Host = host
+	cfg.Port = port
+	cfg.Socket = ""
+	return cfg
+}
+
+// DSN returns the driver DSN for the configuration. TLS settings that need
+// certificates are registered with the driver.
+func (cfg Config) DSN() (string, error) {
+	// The params go through the driver's DSN parser, so that it applies its
+	// own parameters such as interpolateParams and sends the others to the
+	// server as system variables
+	var query []string
+	if cfg.Charset != "" {
+		query = append(query, "charset="+url.QueryEscape(cfg.Charset))
+	}
+	for _, param := range cfg.Params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return "", errors.Errorf("invalid db param %q, expected name=value", param)
+		}
+		query = append(query, name+"="+url.QueryEscape(value))
+	}
+	c, err := mysql.ParseDSN("/?" + strings.Join(query, "&"))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid db params")
+	}
+
+	c.User = cfg.User
+	c.Passwd = cfg.Password
+	c.DBName = cfg.Database
+	c.ParseTime = true
+	if cfg.Collation != "" {
+		c.Collation = cfg.Collation
+	}
+	if cfg.ConnectTimeout > 0 {
+		c.Timeout = cfg.ConnectTimeout
+	}
+	if cfg.ReadTimeout > 0 {
+		c.ReadTimeout = cfg.ReadTimeout
+	}
+	if cfg.WriteTimeout > 0 {
+		c.WriteTimeout = cfg.WriteTimeout
+	}
+
+	if cfg.Socket != "" {
+		c.Net = "unix"
+		c.Addr = cfg.Socket
+	} else {
+		c.Net = "tcp"
+		c.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+
+	tlsName, err := cfg.TLS.register(cfg.Host)
+	if err != nil {
+		return "", err
+	}
+	c.TLSConfig = tlsName
+
+	return c.FormatDSN(), nil
+}
+
+// Open connects to MySQL, checks the connection and applies the pool limits.
+func Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sqlx.ConnectContext(ctx, "mysql", dsn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cfg.Pool.apply(db)
+
+	return db, nil
+}
+
+// apply sets the pool limits on db.
+func (p PoolConfig) apply(db *sqlx.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// register returns the driver's tls parameter for the configuration,
+// registering a custom tls.Config when certificates or verification rules are
+// involved.
+func (t TLSConfig) register(host string) (string, error) {
+	switch t.Mode {
+	case "", TLSDisabled:
+		return "false", nil
+	case TLSPreferred:
+		// Encrypts if the server supports it, without verification
+		return "preferred", nil
+	case TLSRequired, TLSVerifyCA, TLSVerifyIdentity:
+	default:
+		return "", errors.Errorf("unknown TLS mode %q", t.Mode)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var roots *x509.CertPool
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return "", errors.Wrap(err, "read TLS CA file")
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return "", errors.Errorf("no certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return "", errors.Wrap(err, "load TLS client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch t.Mode {
+	case TLSRequired:
+		tlsConfig.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// Check the chain but not the host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(roots)
+	case TLSVerifyIdentity:
+		tlsConfig.ServerName = t.ServerName
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+	}
+
+	// The driver looks TLS configs up by name; derive it from the settings so
+	// that equal settings share one registration.
+	sum := sha256.Sum256([]byte(t.Mode + "\x00" + t.CAFile + "\x00" + t.CertFile + "\x00" + t.KeyFile + "\x00" + tlsConfig.ServerName))
+	name := "books-" + hex.EncodeToString(sum[:8])
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return name, nil
+}
+
+// verifyChain returns a VerifyPeerCertificate callback that checks the
+// server certificate against roots (the system roots if nil) without
+// checking the host name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return errors.WithStack(err)
+	}
+}
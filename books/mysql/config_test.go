@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_ConfigDSN(t *testing.T) {
+	Convey("Config.DSN", t, func() {
+		cfg := Config{
+			Host:     "db.example.com",
+			Port:     3307,
+			User:     "books",
+			Password: "p@ss:word",
+			Database: "books",
+		}
+
+		parse := func(cfg Config) *mysql.Config {
+			dsn, err := cfg.DSN()
+			So(err, ShouldBeNil)
+			parsed, err := mysql.ParseDSN(dsn)
+			So(err, ShouldBeNil)
+			return parsed
+		}
+
+		Convey("Connect over TCP with parseTime", func() {
+			parsed := parse(cfg)
+			So(parsed.Net, ShouldEqual, "tcp")
+			So(parsed.Addr, ShouldEqual, "db.example.com:3307")
+			So(parsed.Passwd, ShouldEqual, "p@ss:word")
+			So(parsed.ParseTime, ShouldBeTrue)
+			So(parsed.TLSConfig, ShouldEqual, "false")
+		})
+
+		Convey("Connect over a Unix socket", func() {
+			cfg.Socket = "/var/run/mysqld/mysqld.sock"
+			parsed := parse(cfg)
+			So(parsed.Net, ShouldEqual, "unix")
+			So(parsed.Addr, ShouldEqual, "/var/run/mysqld/mysqld.sock")
+		})
+
+		Convey("Pass timeouts, charset, collation and params", func() {
+			cfg.ConnectTimeout = 5 * time.Second
+			cfg.ReadTimeout = 30 * time.Second
+			cfg.Charset = "utf8mb4"
+			cfg.Collation = "utf8mb4_unicode_ci"
+			cfg.Params = []string{"time_zone='+00:00'", "interpolateParams=true"}
+
+			parsed := parse(cfg)
+			So(parsed.Timeout, ShouldEqual, 5*time.Second)
+			So(parsed.ReadTimeout, ShouldEqual, 30*time.Second)
+			So(parsed.Collation, ShouldEqual, "utf8mb4_unicode_ci")
+			So(parsed.Params["charset"], ShouldEqual, "utf8mb4")
+			So(parsed.Params["time_zone"], ShouldEqual, "'+00:00'")
+			// Driver parameters are applied, not sent as system variables
+			So(parsed.InterpolateParams, ShouldBeTrue)
+			So(parsed.Params, ShouldNotContainKey, "interpolateParams")
+		})
+
+		Convey("Reject params without a value", func() {
+			cfg.Params = []string{"interpolateParams"}
+			_, err := cfg.DSN()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Register a TLS config for verifying modes", func() {
+			cfg.TLS.Mode = TLSVerifyIdentity
+			dsn, err := cfg.DSN()
+			So(err, ShouldBeNil)
+			So(dsn, ShouldContainSubstring, "tls=books-")
+		})
+
+		Convey("Reject an unknown TLS mode", func() {
+			cfg.TLS.Mode = "sometimes"
+			_, err := cfg.DSN()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func Test_LoadConfig(t *testing.T) {
+	Convey("LoadConfig", t, func() {
+		viper.SetConfigType("yaml")
+		So(viper.MergeConfig(strings.NewReader(`
+loadconfigtest:
+  host: db.example.com
+  port: 3306
+  params:
+    - interpolateParams=true
+    - time_zone='+00:00'
+`)), ShouldBeNil)
+
+		Convey("Keep the case of driver params", func() {
+			cfg := LoadConfig("loadconfigtest")
+			So(cfg.Params, ShouldResemble, []string{"interpolateParams=true", "time_zone='+00:00'"})
+
+			dsn, err := cfg.DSN()
+			So(err, ShouldBeNil)
+			parsed, err := mysql.ParseDSN(dsn)
+			So(err, ShouldBeNil)
+			So(parsed.InterpolateParams, ShouldBeTrue)
+		})
+	})
+}
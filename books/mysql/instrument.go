@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/books/books"
+	"github.com/books/metrics"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the repository calls.
+var tracer = otel.Tracer("github.com/books/books/mysql")
+
+// instrumentedBookRepository traces every BookRepository call and records its
+// duration in the books_repository_query_duration_seconds histogram.
+type instrumentedBookRepository struct {
+	repo books.BookRepository
+}
+
+// call is a repository call being instrumented.
+type call struct {
+	method string
+	start  time.Time
+	span   trace.Span
+}
+
+// begin starts instrumenting a call to method.
+func begin(ctx context.Context, method string) (context.Context, call) {
+	ctx, span := tracer.Start(ctx, "mysql BookRepository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL, semconv.DBOperationName(method)),
+	)
+	return ctx, call{method: method, start: time.Now(), span: span}
+}
+
+// end finishes instrumenting a call that returned err. Domain errors such as
+// ErrBookNotFound mean the query itself succeeded.
+func (c call) end(err error) {
+	failed := err != nil &&
+		!errors.Is(err, books.ErrBookNotFound) &&
+		!errors.Is(err, books.ErrBookAlreadyExists)
+	metrics.ObserveQuery("mysql", c.method, time.Since(c.start), failed)
+
+	if failed {
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+	}
+	c.span.End()
+}
+
+// Create creates a new book in the database.
+func (r instrumentedBookRepository) Create(ctx context.Context, book books.Book) (*books.Book, error) {
+	ctx, c := begin(ctx, "Create")
+	created, err := r.repo.Create(ctx, book)
+	c.end(err)
+	return created, err
+}
+
+// GetByID retrieves a book by its ID.
+func (r instrumentedBookRepository) GetByID(ctx context.Context, id int64) (*books.Book, error) {
+	ctx, c := begin(ctx, "GetByID")
+	book, err := r.repo.GetByID(ctx, id)
+	c.end(err)
+	return book, err
+}
+
+// GetAll retrieves all books (excluding deleted ones).
+func (r instrumentedBookRepository) GetAll(ctx context.Context, author *string, limit, offset int) ([]books.Book, error) {
+	ctx, c := begin(ctx, "GetAll")
+	bookList, err := r.repo.GetAll(ctx, author, limit, offset)
+	c.end(err)
+	return bookList, err
+}
+
+// Update updates an existing book.
+func (r instrumentedBookRepository) Update(ctx context.Context, id int64, book books.Book) (*books.Book, error) {
+	ctx, c := begin(ctx, "Update")
+	updated, err := r.repo.Update(ctx, id, book)
+	c.end(err)
+	return updated, err
+}
+
+// Delete soft deletes a book by setting deletedAt.
+func (r instrumentedBookRepository) Delete(ctx context.Context, id int64) error {
+	ctx, c := begin(ctx, "Delete")
+	err := r.repo.Delete(ctx, id)
+	c.end(err)
+	return err
+}
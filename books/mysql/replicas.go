@@ -0,0 +1,178 @@
+package mysql
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// replica is a read replica and its last known health.
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// Replicas routes reads to a set of read replicas in round-robin order,
+// skipping the ones that failed their last health check.
+type Replicas struct {
+	replicas []*replica
+	interval time.Duration
+	next     atomic.Uint64
+	stop     context.CancelFunc
+	done     sync.WaitGroup
+}
+
+// defaultCheckInterval is used when NewReplicas is given no interval.
+const defaultCheckInterval = 5 * time.Second
+
+// NewReplicas returns a replica set. names are used in log messages and must
+// be in the same order as dbs. Replicas start out healthy and are pinged
+// every checkInterval.
+func NewReplicas(names []string, dbs []*sqlx.DB, checkInterval time.Duration) *Replicas {
+	if checkInterval <= 0 {
+		checkInterval = defaultCheckInterval
+	}
+	rs := &Replicas{interval: checkInterval}
+	for i, db := range dbs {
+		r := &replica{name: names[i], db: db}
+		r.healthy.Store(true)
+		rs.replicas = append(rs.replicas, r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rs.stop = cancel
+	if len(rs.replicas) > 0 {
+		rs.done.Add(1)
+		go rs.checkLoop(ctx)
+	}
+
+	return rs
+}
+
+// OpenReplicas connects to the read replicas at addrs (host:port) with the
+// credentials and options of cfg. A replica that cannot be reached is not an
+// error: it starts out unhealthy and is retried by the health checks.
+func OpenReplicas(ctx context.Context, cfg Config, addrs []string, checkInterval time.Duration) (*Replicas, error) {
+	dbs := make([]*sqlx.DB, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid read replica address %q", addr)
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid read replica address %q", addr)
+		}
+
+		dsn, err := cfg.WithAddr(host, portNum).DSN()
+		if err != nil {
+			return nil, err
+		}
+
+		// Open does not connect, so a replica that is down does not fail startup
+		db, err := sqlx.Open("mysql", dsn)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		cfg.Pool.apply(db)
+		dbs = append(dbs, db)
+	}
+
+	rs := NewReplicas(addrs, dbs, checkInterval)
+	rs.Check(ctx)
+	return rs, nil
+}
+
+// pick returns the next healthy replica, or nil if there is none.
+func (rs *Replicas) pick() *sqlx.DB {
+	if rs == nil || len(rs.replicas) == 0 {
+		return nil
+	}
+
+	start := rs.next.Add(1)
+	for i := 0; i < len(rs.replicas); i++ {
+		r := rs.replicas[(start+uint64(i))%uint64(len(rs.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+// Healthy returns the number of replicas that passed their last health check.
+func (rs *Replicas) Healthy() int {
+	healthy := 0
+	for _, r := range rs.replicas {
+		if r.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// DBs returns the replica connections by name.
+func (rs *Replicas) DBs() map[string]*sqlx.DB {
+	dbs := make(map[string]*sqlx.DB, len(rs.replicas))
+	for _, r := range rs.replicas {
+		dbs[r.name] = r.db
+	}
+	return dbs
+}
+
+// checkLoop pings every replica each check interval until ctx is cancelled.
+func (rs *Replicas) checkLoop(ctx context.Context) {
+	defer rs.done.Done()
+
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rs.Check(ctx)
+		}
+	}
+}
+
+// Check pings every replica once and records the result. Each ping may take
+// up to the check interval.
+func (rs *Replicas) Check(ctx context.Context) {
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, rs.interval)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.InfoContext(ctx, "read replica is healthy again", "replica", r.name)
+			} else {
+				slog.WarnContext(ctx, "read replica failed its health check, sending its reads to the primary", "replica", r.name, "error", err.Error())
+			}
+		}
+	}
+}
+
+// Close stops the health checks and closes every replica connection.
+func (rs *Replicas) Close() error {
+	rs.stop()
+	rs.done.Wait()
+
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
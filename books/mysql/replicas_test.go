@@ -0,0 +1,159 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/books/books/cache/cachetest"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_Replicas(t *testing.T) {
+	Convey("Replicas", t, func() {
+		// sqlx.Open does not connect, so no server is needed to test routing
+		var dbs []*sqlx.DB
+		for i := 0; i < 3; i++ {
+			db, err := sqlx.Open("mysql", "user:password@tcp(127.0.0.1:1)/books")
+			So(err, ShouldBeNil)
+			dbs = append(dbs, db)
+		}
+		replicas := NewReplicas([]string{"a", "b", "c"}, dbs, time.Hour)
+		Reset(func() { replicas.Close() })
+
+		Convey("Rotate through healthy replicas", func() {
+			seen := map[*sqlx.DB]int{}
+			for i := 0; i < 6; i++ {
+				seen[replicas.pick()]++
+			}
+			So(seen, ShouldHaveLength, 3)
+			So(seen[dbs[0]], ShouldEqual, 2)
+		})
+
+		Convey("Skip unhealthy replicas", func() {
+			replicas.replicas[0].healthy.Store(false)
+			replicas.replicas[2].healthy.Store(false)
+
+			for i := 0; i < 3; i++ {
+				So(replicas.pick(), ShouldEqual, dbs[1])
+			}
+			So(replicas.Healthy(), ShouldEqual, 1)
+		})
+
+		Convey("Return nil when no replica is healthy", func() {
+			for _, r := range replicas.replicas {
+				r.healthy.Store(false)
+			}
+			So(replicas.pick(), ShouldBeNil)
+		})
+
+		Convey("Mark replicas that do not answer as unhealthy", func() {
+			replicas.interval = 100 * time.Millisecond
+			replicas.Check(context.Background())
+			So(replicas.Healthy(), ShouldEqual, 0)
+		})
+	})
+}
+
+// booksDriver is a database driver whose queries return one book titled
+// title, standing in for a primary or a replica at some point in time.
+type booksDriver struct{ title string }
+
+func (d booksDriver) Open(name string) (driver.Conn, error) { return booksConn(d), nil }
+
+func (d booksDriver) Connect(ctx context.Context) (driver.Conn, error) { return booksConn(d), nil }
+
+func (d booksDriver) Driver() driver.Driver { return d }
+
+type booksConn booksDriver
+
+func (c booksConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c booksConn) Close() error { return nil }
+
+func (c booksConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c booksConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &bookRows{title: c.title}, nil
+}
+
+// bookRows holds the single row of a booksConn query.
+type bookRows struct {
+	title string
+	done  bool
+}
+
+func (r *bookRows) Columns() []string {
+	return []string{"id", "title", "author", "isbn", "description", "publishedAt", "createdAt", "updatedAt", "deletedAt"}
+}
+
+func (r *bookRows) Close() error { return nil }
+
+func (r *bookRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	copy(dest, []driver.Value{int64(1), r.title, "Le Guin", "", "", at, at, at, nil})
+	return nil
+}
+
+func Test_ReplicaReadsCache(t *testing.T) {
+	Convey("Caching of replica reads", t, func() {
+		server := cachetest.NewCluster()
+		defer server.Close()
+		viper.Set("redis.mode", cache.ModeSingle)
+		viper.Set("redis.dsn", server.Addr())
+		defer viper.Set("redis.mode", nil)
+		defer viper.Set("redis.dsn", nil)
+
+		ctx := context.Background()
+		c, err := cache.NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		// The replica has not received the write that renamed the book yet
+		primary := sqlx.NewDb(sql.OpenDB(booksDriver{title: "The Dispossessed: An Ambiguous Utopia"}), "mysql")
+		defer primary.Close()
+		lagging := sqlx.NewDb(sql.OpenDB(booksDriver{title: "The Dispossessed"}), "mysql")
+		replicas := NewReplicas([]string{"lagging"}, []*sqlx.DB{lagging}, time.Hour)
+		defer replicas.Close()
+		service := books.NewBookService(NewReplicatedRepositoryProvider(primary, replicas), c)
+
+		cached := func(key string) bool {
+			So(c.Drain(ctx), ShouldBeNil)
+			_, ok := server.Get(key)
+			return ok
+		}
+
+		Convey("Do not cache what a replica answered", func() {
+			list, err := service.GetAll(ctx, nil, 0, 0)
+			So(err, ShouldBeNil)
+			So(list[0].Title, ShouldEqual, "The Dispossessed")
+			So(cached("books:books:getall:v0:all"), ShouldBeFalse)
+		})
+
+		Convey("Cache what the primary answered", func() {
+			ctx := books.WithReadPin(ctx)
+			books.PinReads(ctx)
+
+			list, err := service.GetAll(ctx, nil, 0, 0)
+			So(err, ShouldBeNil)
+			So(list[0].Title, ShouldEqual, "The Dispossessed: An Ambiguous Utopia")
+			So(cached("books:books:getall:v0:all"), ShouldBeTrue)
+		})
+	})
+}
@@ -1,13 +1,19 @@
 package mysql
 
 import (
+	"context"
+
 	"github.com/books/books"
+	"github.com/books/books/sqltx"
 	"github.com/jmoiron/sqlx"
 )
 
 // RepositoryProvider manages all repositories.
 type RepositoryProvider struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	replicas *Replicas
+	// tx is set on providers passed to WithTx callbacks.
+	tx *sqlx.Tx
 }
 
 // NewRepositoryProvider returns a new RepositoryProvider.
@@ -15,8 +21,33 @@ func NewRepositoryProvider(db *sqlx.DB) *RepositoryProvider {
 	return &RepositoryProvider{db: db}
 }
 
-// Book returns a new BookRepository.
+// NewReplicatedRepositoryProvider returns a RepositoryProvider that sends
+// reads to replicas and writes to db. Reads following a write in a context
+// created by books.WithReadPin go to db as well.
+func NewReplicatedRepositoryProvider(db *sqlx.DB, replicas *Replicas) *RepositoryProvider {
+	return &RepositoryProvider{db: db, replicas: replicas}
+}
+
+// Book returns a new BookRepository. Its calls are recorded in the
+// repository metrics.
 func (rp *RepositoryProvider) Book() books.BookRepository {
-	return NewBookRepository(rp.db)
+	if rp.tx != nil {
+		return instrumentedBookRepository{repo: NewBookRepository(rp.tx)}
+	}
+	return instrumentedBookRepository{repo: &BookRepository{db: rp.db, replicas: rp.replicas}}
 }
 
+// WithTx runs fn with a provider whose repositories share one transaction.
+// It is committed if fn returns nil and rolled back otherwise. The whole
+// transaction is run again on deadlocks and lock wait timeouts, so fn must be
+// safe to retry. Nested calls join the outer transaction.
+func (rp *RepositoryProvider) WithTx(ctx context.Context, fn func(books.RepositoryProvider) error) error {
+	if rp.tx != nil {
+		return fn(rp)
+	}
+	return retry(ctx, rp.db, func() error {
+		return sqltx.Run(ctx, rp.db, func(tx *sqlx.Tx) error {
+			return fn(&RepositoryProvider{db: rp.db, tx: tx})
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// MySQL error numbers of transient lock conflicts.
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// Retry settings for transient lock conflicts.
+const (
+	retryAttempts = 3
+	retryBackoff  = 20 * time.Millisecond
+)
+
+// isRetryable reports whether err is a deadlock or lock wait timeout. MySQL
+// rolls back the failed statement (and the whole transaction for a deadlock),
+// so running it again is safe.
+func isRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// retryable, or retryAttempts attempts have been made. fn must be idempotent.
+// Inside a caller's transaction nothing is retried: a deadlock has already
+// rolled back the caller's earlier statements, so RepositoryProvider.WithTx
+// retries the whole transaction instead.
+func retry(ctx context.Context, e sqlx.ExtContext, fn func() error) error {
+	if _, ok := e.(*sqlx.DB); !ok {
+		return fn()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt == retryAttempts || !isRetryable(err) {
+			return err
+		}
+
+		// Jitter so that the deadlocked transactions do not collide again
+		backoff := retryBackoff*time.Duration(attempt) + time.Duration(rand.Int63n(int64(retryBackoff)))
+		slog.WarnContext(ctx, "retrying after a lock conflict", "attempt", attempt, "backoff", backoff.String(), "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Retry(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("retry", t, func() {
+		db, err := sqlx.Open("mysql", "user:password@tcp(127.0.0.1:1)/books")
+		So(err, ShouldBeNil)
+		Reset(func() { db.Close() })
+
+		deadlock := errors.WithStack(&mysql.MySQLError{Number: errDeadlock})
+		calls := 0
+
+		Convey("Retry deadlocks until the call succeeds", func() {
+			err := retry(ctx, db, func() error {
+				calls++
+				if calls < retryAttempts {
+					return deadlock
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, retryAttempts)
+		})
+
+		Convey("Give up after retryAttempts attempts", func() {
+			err := retry(ctx, db, func() error {
+				calls++
+				return &mysql.MySQLError{Number: errLockWaitTimeout}
+			})
+			So(isRetryable(err), ShouldBeTrue)
+			So(calls, ShouldEqual, retryAttempts)
+		})
+
+		Convey("Do not retry other errors", func() {
+			err := retry(ctx, db, func() error {
+				calls++
+				return &mysql.MySQLError{Number: 1062}
+			})
+			So(err, ShouldNotBeNil)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Do not retry inside a caller's transaction", func() {
+			err := retry(ctx, &sqlx.Tx{}, func() error {
+				calls++
+				return deadlock
+			})
+			So(err, ShouldEqual, deadlock)
+			So(calls, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/books/books/sqltx"
+	"github.com/jmoiron/sqlx"
+)
+
+// transact runs fn in the transaction e belongs to, or in a new one if e is
+// not a transaction.
+func transact(ctx context.Context, e sqlx.ExtContext, fn func(tx sqlx.ExtContext) error) error {
+	if db, ok := e.(*sqlx.DB); ok {
+		return sqltx.Run(ctx, db, func(tx *sqlx.Tx) error {
+			return fn(tx)
+		})
+	}
+	return fn(e)
+}
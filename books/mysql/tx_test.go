@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/books/books"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// deadlockDriver is a database driver whose first deadlocks statements fail
+// with a deadlock. It counts statements and transaction outcomes.
+type deadlockDriver struct {
+	deadlocks int
+
+	execs, commits, rollbacks int
+}
+
+func (d *deadlockDriver) Open(name string) (driver.Conn, error) { return deadlockConn{d}, nil }
+
+func (d *deadlockDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return deadlockConn{d}, nil
+}
+
+func (d *deadlockDriver) Driver() driver.Driver { return d }
+
+type deadlockConn struct{ d *deadlockDriver }
+
+func (c deadlockConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c deadlockConn) Close() error { return nil }
+
+func (c deadlockConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c deadlockConn) Commit() error {
+	c.d.commits++
+	return nil
+}
+
+func (c deadlockConn) Rollback() error {
+	c.d.rollbacks++
+	return nil
+}
+
+func (c deadlockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.execs++
+	if c.d.execs <= c.d.deadlocks {
+		return nil, &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found when trying to get lock"}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func Test_WithTx(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Transactions of the book service", t, func() {
+		d := &deadlockDriver{}
+		db := sqlx.NewDb(sql.OpenDB(d), "mysql")
+		Reset(func() { db.Close() })
+
+		service := books.NewBookService(NewRepositoryProvider(db), nil)
+
+		Convey("Run the transaction again after a deadlock", func() {
+			d.deadlocks = 1
+			So(service.Delete(ctx, 1), ShouldBeNil)
+			So(d.execs, ShouldEqual, 2)
+			So(d.rollbacks, ShouldEqual, 1)
+			So(d.commits, ShouldEqual, 1)
+		})
+
+		Convey("Give up after retryAttempts transactions", func() {
+			d.deadlocks = retryAttempts + 1
+			err := service.Delete(ctx, 1)
+			So(isRetryable(err), ShouldBeTrue)
+			So(d.execs, ShouldEqual, retryAttempts)
+			So(d.rollbacks, ShouldEqual, retryAttempts)
+			So(d.commits, ShouldEqual, 0)
+		})
+	})
+}
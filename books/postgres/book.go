@@ -0,0 +1,227 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/books/books"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// uniqueViolation is the PostgreSQL error code for unique constraint violations.
+const uniqueViolation = "23505"
+
+// BookRepository contains all methods to access the books table.
+type BookRepository struct {
+	db sqlx.ExtContext
+}
+
+// NewBookRepository returns a new BookRepository. db is either a *sqlx.DB or
+// a *sqlx.Tx.
+func NewBookRepository(db sqlx.ExtContext) *BookRepository {
+	return &BookRepository{db: db}
+}
+
+// Create creates a new book in the database.
+func (r *BookRepository) Create(ctx context.Context, book books.Book) (*books.Book, error) {
+	var id int64
+	err := r.db.QueryRowxContext(ctx, `
+		INSERT INTO books (
+			title,
+			author,
+			isbn,
+			description,
+			"publishedAt",
+			"createdAt",
+			"updatedAt"
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7
+		)
+		RETURNING id
+	`,
+		book.Title,
+		book.Author,
+		book.ISBN,
+		book.Description,
+		book.PublishedAt.UTC(),
+		book.CreatedAt.UTC(),
+		book.UpdatedAt.UTC(),
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, books.ErrBookAlreadyExists
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	book.ID = id
+	return &book, nil
+}
+
+// GetByID retrieves a book by its ID.
+func (r *BookRepository) GetByID(ctx context.Context, id int64) (*books.Book, error) {
+	var book books.Book
+	err := sqlx.GetContext(ctx, r.db, &book, `
+		SELECT
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			"publishedAt",
+			"createdAt",
+			"updatedAt",
+			"deletedAt"
+		FROM books
+		WHERE id = $1
+		AND "deletedAt" IS NULL
+	`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, books.ErrBookNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &book, nil
+}
+
+// GetAll retrieves all books (excluding deleted ones).
+// If author is provided, filters books by that author.
+// limit and offset are used for pagination. If limit is 0, no limit is applied.
+func (r *BookRepository) GetAll(ctx context.Context, author *string, limit, offset int) ([]books.Book, error) {
+	bookList := []books.Book{}
+	query := `
+		SELECT
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			"publishedAt",
+			"createdAt",
+			"updatedAt",
+			"deletedAt"
+		FROM books
+		WHERE "deletedAt" IS NULL
+	`
+	args := []interface{}{}
+
+	if author != nil && *author != "" {
+		args = append(args, *author)
+		// lower() matches the case-insensitive comparisons of the other
+		// backends and the idx_author expression index
+		query += ` AND lower(author) = lower($` + strconv.Itoa(len(args)) + `)`
+	}
+
+	query += ` ORDER BY id ASC`
+
+	if limit > 0 {
+		args = append(args, limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+
+		if offset > 0 {
+			args = append(args, offset)
+			query += ` OFFSET $` + strconv.Itoa(len(args))
+		}
+	}
+
+	err := sqlx.SelectContext(ctx, r.db, &bookList, query, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Ensure we always return a non-nil slice (empty slice instead of nil)
+	// This ensures JSON serialization produces [] instead of null
+	if bookList == nil {
+		bookList = []books.Book{}
+	}
+
+	return bookList, nil
+}
+
+// Update updates an existing book.
+func (r *BookRepository) Update(ctx context.Context, id int64, book books.Book) (*books.Book, error) {
+	var updatedBook books.Book
+	err := sqlx.GetContext(ctx, r.db, &updatedBook, `
+		UPDATE books
+		SET
+			title = $1,
+			author = $2,
+			isbn = $3,
+			description = $4,
+			"publishedAt" = $5,
+			"updatedAt" = $6
+		WHERE id = $7
+		AND "deletedAt" IS NULL
+		RETURNING
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			"publishedAt",
+			"createdAt",
+			"updatedAt",
+			"deletedAt"
+	`,
+		book.Title,
+		book.Author,
+		book.ISBN,
+		book.Description,
+		book.PublishedAt.UTC(),
+		book.UpdatedAt.UTC(),
+		id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, books.ErrBookNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, books.ErrBookAlreadyExists
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	return &updatedBook, nil
+}
+
+// Delete soft deletes a book by setting deletedAt.
+func (r *BookRepository) Delete(ctx context.Context, id int64) error {
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE books
+		SET
+			"deletedAt" = $1
+		WHERE id = $2
+		AND "deletedAt" IS NULL
+	`, now, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if affected == 0 {
+		return books.ErrBookNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/repotest"
+	"github.com/books/config"
+	"github.com/books/migrations"
+	"github.com/spf13/viper"
+)
+
+func Test_BookRepository(t *testing.T) {
+	if err := config.Init(); err != nil {
+		t.Fatalf("Failed to initialize config: %v", err)
+	}
+
+	dsn := viper.GetString("test.postgres.dsn")
+	if dsn == "" {
+		t.Skip("test.postgres.dsn is not set, the PostgreSQL repository is not under test")
+	}
+
+	db, err := Open(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewEmbeddedMigrator(db, "postgres")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	repotest.Run(t, func() books.RepositoryProvider {
+		if _, err := db.Exec("TRUNCATE books RESTART IDENTITY"); err != nil {
+			t.Fatalf("Failed to clear books table: %v", err)
+		}
+		return NewRepositoryProvider(db)
+	})
+
+	repotest.RunKeys(t, func() auth.KeyRepository {
+		if _, err := db.Exec("TRUNCATE api_keys RESTART IDENTITY"); err != nil {
+			t.Fatalf("Failed to clear api_keys table: %v", err)
+		}
+		return NewAPIKeyRepository(db)
+	})
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Config holds the PostgreSQL connection options.
+type Config struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	// SSLMode is passed through as sslmode, e.g. disable, require or verify-full.
+	SSLMode string
+}
+
+// DSN returns the connection URL for the configuration.
+func (cfg Config) DSN() string {
+	query := url.Values{}
+	if cfg.SSLMode != "" {
+		query.Set("sslmode", cfg.SSLMode)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Host + ":" + strconv.Itoa(cfg.Port),
+		Path:     "/" + cfg.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Open connects to PostgreSQL. The schema is managed with the migrations package.
+func Open(ctx context.Context, dsn string) (*sqlx.DB, error) {
+	db, err := sqlx.ConnectContext(ctx, "pgx", dsn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,78 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/books/auth"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// RunKeys runs the conformance suite of auth.KeyRepository implementations.
+// newRepo is called before every test case and must return a repository
+// backed by empty storage.
+func RunKeys(t *testing.T, newRepo func() auth.KeyRepository) {
+	ctx := context.Background()
+
+	Convey("KeyRepository conformance", t, func() {
+		repo := newRepo()
+
+		newKey := func(name string, role auth.Role) *auth.APIKey {
+			_, key, err := auth.GenerateKey(name, role)
+			So(err, ShouldBeNil)
+			created, err := repo.Create(ctx, key)
+			So(err, ShouldBeNil)
+			return created
+		}
+
+		Convey("Persist every field", func() {
+			created := newKey("ci", auth.RoleEditor)
+			So(created.ID, ShouldBeGreaterThan, 0)
+
+			stored, err := repo.GetByPrefix(ctx, created.Prefix)
+			So(err, ShouldBeNil)
+			So(stored.ID, ShouldEqual, created.ID)
+			So(stored.Name, ShouldEqual, "ci")
+			So(stored.Hash, ShouldEqual, created.Hash)
+			So(stored.Role, ShouldEqual, auth.RoleEditor)
+			So(stored.CreatedAt.Equal(created.CreatedAt), ShouldBeTrue)
+			So(stored.RevokedAt, ShouldBeNil)
+		})
+
+		Convey("Return ErrKeyNotFound for unknown prefixes", func() {
+			_, err := repo.GetByPrefix(ctx, "bk_unknown")
+			So(err, ShouldEqual, auth.ErrKeyNotFound)
+		})
+
+		Convey("Return ErrKeyAlreadyExists for a duplicate prefix", func() {
+			created := newKey("ci", auth.RoleReader)
+
+			_, err := repo.Create(ctx, *created)
+			So(err, ShouldEqual, auth.ErrKeyAlreadyExists)
+		})
+
+		Convey("List keys in ID order", func() {
+			first := newKey("first", auth.RoleReader)
+			second := newKey("second", auth.RoleAdmin)
+
+			keys, err := repo.List(ctx)
+			So(err, ShouldBeNil)
+			So(len(keys), ShouldEqual, 2)
+			So(keys[0].ID, ShouldEqual, first.ID)
+			So(keys[1].ID, ShouldEqual, second.ID)
+		})
+
+		Convey("Revoke a key once", func() {
+			created := newKey("ci", auth.RoleReader)
+
+			So(repo.Revoke(ctx, created.ID, time.Now()), ShouldBeNil)
+			stored, err := repo.GetByPrefix(ctx, created.Prefix)
+			So(err, ShouldBeNil)
+			So(stored.Revoked(), ShouldBeTrue)
+
+			So(repo.Revoke(ctx, created.ID, time.Now()), ShouldEqual, auth.ErrKeyRevoked)
+			So(repo.Revoke(ctx, created.ID+1, time.Now()), ShouldEqual, auth.ErrKeyNotFound)
+		})
+	})
+}
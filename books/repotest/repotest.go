@@ -0,0 +1,316 @@
+// Package repotest contains conformance suites for books.RepositoryProvider
+// and auth.KeyRepository implementations, so that every storage backend
+// behaves like the MySQL one.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/books/books"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Run runs the conformance suite. newProvider is called before every test
+// case and must return a provider backed by empty storage.
+func Run(t *testing.T, newProvider func() books.RepositoryProvider) {
+	ctx := context.Background()
+
+	Convey("BookRepository conformance", t, func() {
+		provider := newProvider()
+		repo := provider.Book()
+
+		Convey("Create", func() {
+			Convey("Assign increasing IDs", func() {
+				first := create(repo, newBook("Book 1", "Author A"))
+				second := create(repo, newBook("Book 2", "Author A"))
+
+				So(first.ID, ShouldBeGreaterThan, 0)
+				So(second.ID, ShouldBeGreaterThan, first.ID)
+			})
+
+			Convey("Persist every field", func() {
+				book := newBook("Book 1", "Author A")
+				created := create(repo, book)
+
+				stored, err := repo.GetByID(ctx, created.ID)
+				So(err, ShouldBeNil)
+				So(stored.Title, ShouldEqual, book.Title)
+				So(stored.Author, ShouldEqual, book.Author)
+				So(stored.ISBN, ShouldEqual, book.ISBN)
+				So(stored.Description, ShouldEqual, book.Description)
+				So(stored.PublishedAt.Equal(book.PublishedAt), ShouldBeTrue)
+				So(stored.CreatedAt.Equal(book.CreatedAt), ShouldBeTrue)
+				So(stored.UpdatedAt.Equal(book.UpdatedAt), ShouldBeTrue)
+				So(stored.DeletedAt, ShouldBeNil)
+			})
+
+			Convey("Return ErrBookAlreadyExists for a duplicate title, author and ISBN", func() {
+				create(repo, newBook("Book 1", "Author A"))
+
+				_, err := repo.Create(ctx, newBook("Book 1", "Author A"))
+				So(err, ShouldEqual, books.ErrBookAlreadyExists)
+			})
+
+			Convey("Compare title, author and ISBN case-insensitively", func() {
+				create(repo, newBook("Book 1", "Author A"))
+
+				duplicate := newBook("BOOK 1", "author a")
+				_, err := repo.Create(ctx, duplicate)
+				So(err, ShouldEqual, books.ErrBookAlreadyExists)
+			})
+
+			Convey("Allow the same title by another author", func() {
+				create(repo, newBook("Book 1", "Author A"))
+
+				_, err := repo.Create(ctx, newBook("Book 1", "Author B"))
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("GetByID", func() {
+			Convey("Return ErrBookNotFound for an unknown ID", func() {
+				_, err := repo.GetByID(ctx, 999)
+				So(err, ShouldEqual, books.ErrBookNotFound)
+			})
+
+			Convey("Return ErrBookNotFound for a deleted book", func() {
+				book := create(repo, newBook("Book 1", "Author A"))
+				So(repo.Delete(ctx, book.ID), ShouldBeNil)
+
+				_, err := repo.GetByID(ctx, book.ID)
+				So(err, ShouldEqual, books.ErrBookNotFound)
+			})
+		})
+
+		Convey("GetAll", func() {
+			Convey("Return an empty, non-nil list when there are no books", func() {
+				bookList, err := repo.GetAll(ctx, nil, 0, 0)
+				So(err, ShouldBeNil)
+				So(bookList, ShouldNotBeNil)
+				So(bookList, ShouldBeEmpty)
+			})
+
+			Convey("With books", func() {
+				var ids []int64
+				for i := 1; i <= 5; i++ {
+					author := "Author A"
+					if i%2 == 0 {
+						author = "Author B"
+					}
+					ids = append(ids, create(repo, newBook(fmt.Sprintf("Book %d", i), author)).ID)
+				}
+				So(repo.Delete(ctx, ids[4]), ShouldBeNil)
+
+				Convey("Return non-deleted books ordered by ID", func() {
+					bookList, err := repo.GetAll(ctx, nil, 0, 0)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, ids[:4])
+				})
+
+				Convey("Filter by author", func() {
+					author := "Author B"
+					bookList, err := repo.GetAll(ctx, &author, 0, 0)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, []int64{ids[1], ids[3]})
+				})
+
+				Convey("Filter by author case-insensitively", func() {
+					author := "AUTHOR b"
+					bookList, err := repo.GetAll(ctx, &author, 0, 0)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, []int64{ids[1], ids[3]})
+				})
+
+				Convey("Ignore an empty author filter", func() {
+					author := ""
+					bookList, err := repo.GetAll(ctx, &author, 0, 0)
+					So(err, ShouldBeNil)
+					So(bookList, ShouldHaveLength, 4)
+				})
+
+				Convey("Apply limit", func() {
+					bookList, err := repo.GetAll(ctx, nil, 3, 0)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, ids[:3])
+				})
+
+				Convey("Apply limit and offset", func() {
+					bookList, err := repo.GetAll(ctx, nil, 3, 3)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, ids[3:4])
+				})
+
+				Convey("Return an empty list when offset is beyond the data", func() {
+					bookList, err := repo.GetAll(ctx, nil, 3, 30)
+					So(err, ShouldBeNil)
+					So(bookList, ShouldNotBeNil)
+					So(bookList, ShouldBeEmpty)
+				})
+
+				Convey("Ignore offset without limit", func() {
+					bookList, err := repo.GetAll(ctx, nil, 0, 2)
+					So(err, ShouldBeNil)
+					So(bookList, ShouldHaveLength, 4)
+				})
+
+				Convey("Combine author filter and pagination", func() {
+					author := "Author A"
+					bookList, err := repo.GetAll(ctx, &author, 1, 1)
+					So(err, ShouldBeNil)
+					So(bookIDs(bookList), ShouldResemble, []int64{ids[2]})
+				})
+			})
+		})
+
+		Convey("Update", func() {
+			book := create(repo, newBook("Book 1", "Author A"))
+
+			Convey("Update the book", func() {
+				changes := newBook("Book 1 (Updated)", "Author B")
+				changes.UpdatedAt = changes.UpdatedAt.Add(time.Hour)
+
+				updated, err := repo.Update(ctx, book.ID, changes)
+				So(err, ShouldBeNil)
+				So(updated.ID, ShouldEqual, book.ID)
+				So(updated.Title, ShouldEqual, "Book 1 (Updated)")
+				So(updated.CreatedAt.Equal(book.CreatedAt), ShouldBeTrue)
+
+				stored, err := repo.GetByID(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Title, ShouldEqual, "Book 1 (Updated)")
+				So(stored.Author, ShouldEqual, "Author B")
+				So(stored.UpdatedAt.Equal(changes.UpdatedAt), ShouldBeTrue)
+				So(stored.CreatedAt.Equal(book.CreatedAt), ShouldBeTrue)
+			})
+
+			Convey("Return ErrBookNotFound for an unknown ID", func() {
+				_, err := repo.Update(ctx, 999, newBook("Book 2", "Author A"))
+				So(err, ShouldEqual, books.ErrBookNotFound)
+			})
+
+			Convey("Return ErrBookNotFound for a deleted book", func() {
+				So(repo.Delete(ctx, book.ID), ShouldBeNil)
+
+				_, err := repo.Update(ctx, book.ID, newBook("Book 2", "Author A"))
+				So(err, ShouldEqual, books.ErrBookNotFound)
+			})
+
+			Convey("Return ErrBookAlreadyExists when it would duplicate another book", func() {
+				create(repo, newBook("Book 2", "Author A"))
+
+				_, err := repo.Update(ctx, book.ID, newBook("Book 2", "Author A"))
+				So(err, ShouldEqual, books.ErrBookAlreadyExists)
+			})
+		})
+
+		Convey("Delete", func() {
+			book := create(repo, newBook("Book 1", "Author A"))
+
+			Convey("Soft delete the book", func() {
+				So(repo.Delete(ctx, book.ID), ShouldBeNil)
+
+				bookList, err := repo.GetAll(ctx, nil, 0, 0)
+				So(err, ShouldBeNil)
+				So(bookList, ShouldBeEmpty)
+			})
+
+			Convey("Return ErrBookNotFound for an unknown ID", func() {
+				So(repo.Delete(ctx, 999), ShouldEqual, books.ErrBookNotFound)
+			})
+
+			Convey("Return ErrBookNotFound when deleting twice", func() {
+				So(repo.Delete(ctx, book.ID), ShouldBeNil)
+				So(repo.Delete(ctx, book.ID), ShouldEqual, books.ErrBookNotFound)
+			})
+		})
+
+		Convey("WithTx", func() {
+			errRollback := errors.New("rollback")
+
+			Convey("Commit when fn succeeds", func() {
+				var created *books.Book
+				err := provider.WithTx(ctx, func(tx books.RepositoryProvider) error {
+					var err error
+					created, err = tx.Book().Create(ctx, newBook("Book 1", "Author A"))
+					return err
+				})
+				So(err, ShouldBeNil)
+
+				_, err = repo.GetByID(ctx, created.ID)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Roll back every change when fn fails", func() {
+				book := create(repo, newBook("Book 1", "Author A"))
+
+				err := provider.WithTx(ctx, func(tx books.RepositoryProvider) error {
+					if _, err := tx.Book().Create(ctx, newBook("Book 2", "Author A")); err != nil {
+						return err
+					}
+					if err := tx.Book().Delete(ctx, book.ID); err != nil {
+						return err
+					}
+					return errRollback
+				})
+				So(err, ShouldEqual, errRollback)
+
+				bookList, err := repo.GetAll(ctx, nil, 0, 0)
+				So(err, ShouldBeNil)
+				So(bookIDs(bookList), ShouldResemble, []int64{book.ID})
+			})
+
+			Convey("Join the outer transaction when nested", func() {
+				err := provider.WithTx(ctx, func(tx books.RepositoryProvider) error {
+					err := tx.WithTx(ctx, func(nested books.RepositoryProvider) error {
+						_, err := nested.Book().Create(ctx, newBook("Book 1", "Author A"))
+						return err
+					})
+					if err != nil {
+						return err
+					}
+					return errRollback
+				})
+				So(err, ShouldEqual, errRollback)
+
+				bookList, err := repo.GetAll(ctx, nil, 0, 0)
+				So(err, ShouldBeNil)
+				So(bookList, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+// newBook returns a valid book. Timestamps have microsecond precision, the
+// most every backend can store.
+func newBook(title, author string) books.Book {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return books.Book{
+		Title:       title,
+		Author:      author,
+		ISBN:        "1234567890",
+		Description: "Description of " + title,
+		PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// create creates a book and asserts that it succeeded.
+func create(repo books.BookRepository, book books.Book) *books.Book {
+	created, err := repo.Create(context.Background(), book)
+	So(err, ShouldBeNil)
+	return created
+}
+
+// bookIDs returns the IDs of the books in order.
+func bookIDs(bookList []books.Book) []int64 {
+	ids := make([]int64, 0, len(bookList))
+	for _, book := range bookList {
+		ids = append(ids, book.ID)
+	}
+	return ids
+}
@@ -3,15 +3,27 @@ package books
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/books/books/cache"
+	"github.com/books/metrics"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RepositoryProvider manages all repositories.
 type RepositoryProvider interface {
 	Book() BookRepository
+
+	// WithTx runs fn with a provider whose repositories share a single
+	// transaction. It is committed if fn returns nil and rolled back
+	// otherwise. Calling WithTx on that provider joins the same transaction.
+	WithTx(ctx context.Context, fn func(RepositoryProvider) error) error
 }
 
 // BookService manages book operations.
@@ -29,7 +41,10 @@ func NewBookService(repo RepositoryProvider, c *cache.Cache) *BookService {
 }
 
 // Create creates a new book.
-func (s *BookService) Create(ctx context.Context, book Book) (*Book, error) {
+func (s *BookService) Create(ctx context.Context, book Book) (_ *Book, err error) {
+	ctx, span := startSpan(ctx, "Create")
+	defer func() { endSpan(span, err) }()
+
 	// Set timestamps
 	now := time.Now().UTC()
 	book.CreatedAt = now
@@ -40,47 +55,45 @@ func (s *BookService) Create(ctx context.Context, book Book) (*Book, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	// Invalidate the "all books" cache after creating a new book
-	if s.cache != nil {
-		cacheKey := getAllCacheKey(nil) // nil means "all books"
-		go func() {
-			_ = s.cache.Delete(context.Background(), cacheKey)
-		}()
-	}
+	// Invalidate every cached list page after creating a new book
+	s.invalidate(ctx)
 
 	return createdBook, nil
 }
 
 // GetByID retrieves a book by ID.
-func (s *BookService) GetByID(ctx context.Context, id int64) (*Book, error) {
+func (s *BookService) GetByID(ctx context.Context, id int64) (_ *Book, err error) {
+	ctx, span := startSpan(ctx, "GetByID", attribute.Int64("book.id", id))
+	defer func() { endSpan(span, err) }()
+
 	// Try to get from cache first (if cache is available)
 	if s.cache != nil {
 		cacheKey := getByIDCacheKey(id)
 		var book Book
 		err := s.cache.Get(ctx, cacheKey, &book)
+		countLookup(ctx, cache.FamilyByID, err)
 		if err == nil {
 			// Cache hit, return cached value
+			s.recordHit(ctx, id)
 			return &book, nil
 		}
 	}
 
 	// Cache miss or error, fetch from database
+	ctx, fromReplica := trackReplicaReads(ctx)
 	book, err := s.repo.Book().GetByID(ctx, id)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	// Write to cache asynchronously
+	// Write to cache asynchronously, unless a lagging replica may have
+	// answered with a book older than the last invalidation
 	if s.cache != nil && book != nil {
-		cacheKey := getByIDCacheKey(id)
-		go func() {
-			_ = s.cache.Set(
-				context.Background(),
-				cacheKey,
-				book,
-				time.Hour*1, // Cache for 1 hour
-			)
-		}()
+		s.recordHit(ctx, id)
+		if !fromReplica.Load() {
+			cacheKey := getByIDCacheKey(id)
+			_ = s.cache.SetAsync(ctx, cacheKey, book, s.cache.TTL(cache.FamilyByID))
+		}
 	}
 
 	return book, nil
@@ -89,47 +102,99 @@ func (s *BookService) GetByID(ctx context.Context, id int64) (*Book, error) {
 // GetAll retrieves all books.
 // If author is provided, filters books by that author.
 // limit and offset are used for pagination. If limit is 0, no limit is applied.
-func (s *BookService) GetAll(ctx context.Context, author *string, limit, offset int) ([]Book, error) {
-	// Try to get from cache first (if cache is available and not paginated)
-	// Note: We don't cache paginated results
-	if s.cache != nil && limit == 0 && offset == 0 {
-		cacheKey := getAllCacheKey(author)
-		var books []Book
-		err := s.cache.Get(ctx, cacheKey, &books)
-		if err == nil {
-			// Cache hit, return cached value
-			return books, nil
+func (s *BookService) GetAll(ctx context.Context, author *string, limit, offset int) (_ []Book, err error) {
+	ctx, span := startSpan(ctx, "GetAll", attribute.Int("limit", limit), attribute.Int("offset", offset))
+	defer func() { endSpan(span, err) }()
+
+	// Try to get from cache first (if cache is available)
+	// The list version is read before querying the database so that a write
+	// racing with this request invalidates the page we are about to store.
+	var cacheKey string
+	if s.cache != nil {
+		version, err := s.cache.Counter(ctx, listVersionKey)
+		if err != nil {
+			countLookup(ctx, cache.FamilyList, err)
+		} else {
+			cacheKey = getAllCacheKey(version, author, limit, offset)
+			var books []Book
+			err := s.cache.Get(ctx, cacheKey, &books)
+			countLookup(ctx, cache.FamilyList, err)
+			if err == nil {
+				// Cache hit, return cached value
+				return books, nil
+			}
 		}
 	}
 
 	// Cache miss or error, fetch from database
+	ctx, fromReplica := trackReplicaReads(ctx)
 	books, err := s.repo.Book().GetAll(ctx, author, limit, offset)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	// Write to cache asynchronously (only for non-paginated requests)
-	if s.cache != nil && limit == 0 && offset == 0 {
-		cacheKey := getAllCacheKey(author)
-		go func() {
-			_ = s.cache.Set(
-				context.Background(),
-				cacheKey,
-				books,
-				time.Hour*1, // Cache for 1 hour
-			)
-		}()
+	// Write to cache asynchronously, unless a lagging replica may have
+	// answered with a page older than the list version
+	if s.cache != nil && cacheKey != "" && !fromReplica.Load() {
+		_ = s.cache.SetAsync(ctx, cacheKey, books, s.cache.TTL(cache.FamilyList))
 	}
 
 	return books, nil
 }
 
-// getAllCacheKey generates a cache key for GetAll based on author filter.
-func getAllCacheKey(author *string) string {
+// countLookup counts the result of a cache lookup in the cache metrics and
+// records it on the current span.
+func countLookup(ctx context.Context, family cache.Family, err error) {
+	result := metrics.CacheError
+	switch {
+	case err == nil:
+		result = metrics.CacheHit
+	case errors.Is(err, cache.ErrCacheMiss):
+		result = metrics.CacheMiss
+	}
+	metrics.CacheLookup(string(family), result)
+	if result == metrics.CacheError {
+		slog.DebugContext(ctx, "cache lookup failed", "family", string(family), "error", err.Error())
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.result", result))
+}
+
+// listVersionKey holds a counter that is incremented on every write. It is
+// part of every list cache key, so bumping it invalidates all cached pages at
+// once without having to enumerate them.
+const listVersionKey = "books:books:getall:version"
+
+// getAllCacheKey generates a cache key for GetAll based on the list version
+// and the normalized query (author filter, limit and offset).
+func getAllCacheKey(version int64, author *string, limit, offset int) string {
+	query := url.Values{}
 	if author != nil && *author != "" {
-		return fmt.Sprintf("books:books:getall:author:%s", *author)
+		query.Set("author", *author)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+		if offset > 0 {
+			query.Set("offset", strconv.Itoa(offset))
+		}
+	}
+
+	// Encode sorts by key, so equivalent queries share the same key
+	normalized := query.Encode()
+	if normalized == "" {
+		normalized = "all"
+	}
+	return fmt.Sprintf("books:books:getall:v%d:%s", version, normalized)
+}
+
+// invalidate removes the given keys and bumps the list version so that all
+// cached list pages are invalidated. Failures are counted and logged by the
+// cache, which applies the invalidation before Redis is used again; they never
+// fail the write that triggered them.
+func (s *BookService) invalidate(ctx context.Context, keys ...string) {
+	if s.cache == nil {
+		return
 	}
-	return "books:books:getall:all"
+	_ = s.cache.Invalidate(ctx, keys, []string{listVersionKey})
 }
 
 // getByIDCacheKey generates a cache key for GetByID based on book ID.
@@ -138,7 +203,10 @@ func getByIDCacheKey(id int64) string {
 }
 
 // Update updates an existing book.
-func (s *BookService) Update(ctx context.Context, id int64, book Book) (*Book, error) {
+func (s *BookService) Update(ctx context.Context, id int64, book Book) (_ *Book, err error) {
+	ctx, span := startSpan(ctx, "Update", attribute.Int64("book.id", id))
+	defer func() { endSpan(span, err) }()
+
 	// Validate required fields
 	if book.Title == "" {
 		return nil, errors.Wrap(ErrInvalidBookData, "title is required")
@@ -155,41 +223,91 @@ func (s *BookService) Update(ctx context.Context, id int64, book Book) (*Book, e
 		book.PublishedAt = time.Now().UTC()
 	}
 
-	updatedBook, err := s.repo.Book().Update(ctx, id, book)
+	var updatedBook *Book
+	err = s.repo.WithTx(ctx, func(repo RepositoryProvider) error {
+		var err error
+		updatedBook, err = repo.Book().Update(ctx, id, book)
+		return err
+	})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	// Invalidate the cache for this book and the "all books" cache after updating
-	if s.cache != nil {
-		bookCacheKey := getByIDCacheKey(id)
-		allBooksCacheKey := getAllCacheKey(nil)
-		go func() {
-			_ = s.cache.Delete(context.Background(), bookCacheKey)
-			_ = s.cache.Delete(context.Background(), allBooksCacheKey)
-		}()
-	}
+	// Invalidate the cache for this book and every cached list page after updating
+	s.invalidate(ctx, getByIDCacheKey(id))
 
 	return updatedBook, nil
 }
 
 // Delete soft deletes a book.
-func (s *BookService) Delete(ctx context.Context, id int64) error {
-	err := s.repo.Book().Delete(ctx, id)
+func (s *BookService) Delete(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "Delete", attribute.Int64("book.id", id))
+	defer func() { endSpan(span, err) }()
+
+	err = s.repo.WithTx(ctx, func(repo RepositoryProvider) error {
+		return repo.Book().Delete(ctx, id)
+	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	// Invalidate the cache for this book and the "all books" cache after deleting
-	if s.cache != nil {
-		bookCacheKey := getByIDCacheKey(id)
-		allBooksCacheKey := getAllCacheKey(nil)
-		go func() {
-			_ = s.cache.Delete(context.Background(), bookCacheKey)
-			_ = s.cache.Delete(context.Background(), allBooksCacheKey)
-		}()
-	}
+	// Invalidate the cache for this book and every cached list page after deleting
+	s.invalidate(ctx, getByIDCacheKey(id))
 
 	return nil
 }
 
+// errCacheNotConfigured is returned by cache maintenance methods when the
+// service runs without a cache.
+var errCacheNotConfigured = errors.New("cache is not configured")
+
+// EvictBook removes the cached copy of a book and invalidates every cached
+// list page, since the book may appear in any of them.
+func (s *BookService) EvictBook(ctx context.Context, id int64) error {
+	if s.cache == nil {
+		return errCacheNotConfigured
+	}
+	if err := s.cache.Delete(ctx, getByIDCacheKey(id)); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(s.cache.Incr(ctx, listVersionKey))
+}
+
+// EvictAuthor removes the cached list pages filtered by author and returns
+// how many keys were deleted.
+func (s *BookService) EvictAuthor(ctx context.Context, author string) (int64, error) {
+	if s.cache == nil {
+		return 0, errCacheNotConfigured
+	}
+	deleted, err := s.cache.DeleteMatching(ctx, "books:books:getall:*author=*", func(key string) bool {
+		return cacheKeyAuthor(key) != author
+	})
+	return deleted, errors.WithStack(err)
+}
+
+// EvictPrefix removes the cached entries whose key starts with prefix and
+// returns how many keys were deleted. The list version and the request counts
+// are kept: resetting the version would make list pages cached under older
+// versions current again.
+func (s *BookService) EvictPrefix(ctx context.Context, prefix string) (int64, error) {
+	if s.cache == nil {
+		return 0, errCacheNotConfigured
+	}
+	deleted, err := s.cache.DeleteByPrefix(ctx, prefix, func(key string) bool {
+		return key == listVersionKey || key == popularBooksKey
+	})
+	return deleted, errors.WithStack(err)
+}
+
+// cacheKeyAuthor returns the author filter encoded in a GetAll cache key.
+func cacheKeyAuthor(key string) string {
+	parts := strings.SplitN(key, ":", 5)
+	if len(parts) != 5 {
+		return ""
+	}
+	query, err := url.ParseQuery(parts[4])
+	if err != nil {
+		return ""
+	}
+	return query.Get("author")
+}
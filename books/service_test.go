@@ -0,0 +1,219 @@
+package books_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/books/books/cache/cachetest"
+	"github.com/books/books/memory"
+	"github.com/books/testdata"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_GetAllCacheKey(t *testing.T) {
+	Convey("getAllCacheKey", t, func() {
+		author := "Le Guin & Banks"
+		empty := ""
+
+		So(books.GetAllCacheKey(0, nil, 0, 0), ShouldEqual, "books:books:getall:v0:all")
+		So(books.GetAllCacheKey(3, nil, 0, 0), ShouldEqual, "books:books:getall:v3:all")
+		So(books.GetAllCacheKey(1, &author, 10, 20), ShouldEqual, "books:books:getall:v1:author=Le+Guin+%26+Banks&limit=10&offset=20")
+
+		Convey("Share the key of equivalent queries", func() {
+			So(books.GetAllCacheKey(0, &empty, 0, 0), ShouldEqual, books.GetAllCacheKey(0, nil, 0, 0))
+			// The offset is ignored without a limit, as by the repositories
+			So(books.GetAllCacheKey(0, nil, 0, 5), ShouldEqual, books.GetAllCacheKey(0, nil, 0, 0))
+			So(books.GetAllCacheKey(0, nil, 10, 0), ShouldEqual, "books:books:getall:v0:limit=10")
+		})
+
+		Convey("Keep authors that look like other parameters apart", func() {
+			tricky := "x&limit=10"
+			So(books.GetAllCacheKey(0, &tricky, 0, 0), ShouldNotEqual, books.GetAllCacheKey(0, &author, 10, 0))
+			So(books.GetAllCacheKey(0, &tricky, 0, 0), ShouldEqual, "books:books:getall:v0:author=x%26limit%3D10")
+		})
+	})
+}
+
+func Test_ListInvalidation(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithCache()
+	defer suite.Close()
+	ctx := context.Background()
+
+	live := Convey
+	if suite.Cache() == nil {
+		live = SkipConvey
+	}
+	live("Invalidate the cached list pages on writes", t, func() {
+		So(suite.Cache().FlushDB(ctx), ShouldBeNil)
+		// Invalidations are applied before the writes return
+		viper.Set("cache.write.sync_invalidation", true)
+		defer viper.Set("cache.write.sync_invalidation", nil)
+		c, err := cache.NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		repo := memory.NewRepositoryProvider()
+		service := books.NewBookService(repo, c)
+		book, err := service.Create(ctx, books.Book{
+			Title:       "The Dispossessed",
+			Author:      "Le Guin",
+			PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		So(err, ShouldBeNil)
+
+		// Cache a stale page for every query, as a previous version
+		version, err := c.Counter(ctx, books.ListVersionKey)
+		So(err, ShouldBeNil)
+		author := "Le Guin"
+		queries := []struct {
+			author        *string
+			limit, offset int
+		}{
+			{nil, 0, 0},
+			{&author, 0, 0},
+			{nil, 10, 0},
+			{&author, 1, 1},
+		}
+		for _, q := range queries {
+			key := books.GetAllCacheKey(version, q.author, q.limit, q.offset)
+			So(c.Set(ctx, key, []books.Book{}, time.Minute), ShouldBeNil)
+		}
+		for _, q := range queries {
+			cached, err := service.GetAll(ctx, q.author, q.limit, q.offset)
+			So(err, ShouldBeNil)
+			So(cached, ShouldBeEmpty)
+		}
+
+		// expectInvalidated checks that the version was bumped and that no
+		// query is answered from a page cached before the write.
+		expectInvalidated := func() {
+			bumped, err := c.Counter(ctx, books.ListVersionKey)
+			So(err, ShouldBeNil)
+			So(bumped, ShouldBeGreaterThan, version)
+
+			for _, q := range queries {
+				fresh, err := repo.Book().GetAll(ctx, q.author, q.limit, q.offset)
+				So(err, ShouldBeNil)
+				got, err := service.GetAll(ctx, q.author, q.limit, q.offset)
+				So(err, ShouldBeNil)
+				So(got, ShouldResemble, fresh)
+			}
+		}
+
+		Convey("Create", func() {
+			_, err := service.Create(ctx, books.Book{
+				Title:       "The Lathe of Heaven",
+				Author:      "Le Guin",
+				PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			})
+			So(err, ShouldBeNil)
+			expectInvalidated()
+		})
+
+		Convey("Update", func() {
+			book.Title = "The Dispossessed: An Ambiguous Utopia"
+			_, err := service.Update(ctx, book.ID, *book)
+			So(err, ShouldBeNil)
+			expectInvalidated()
+		})
+
+		Convey("Delete", func() {
+			So(service.Delete(ctx, book.ID), ShouldBeNil)
+			expectInvalidated()
+		})
+	})
+}
+
+func Test_ClusterInvalidation(t *testing.T) {
+	Convey("Invalidate the cache of a Redis Cluster on writes", t, func() {
+		cluster := cachetest.NewCluster()
+		defer cluster.Close()
+
+		viper.Set("redis.mode", cache.ModeCluster)
+		viper.Set("redis.addrs", []string{cluster.Addr()})
+		viper.Set("cache.write.sync_invalidation", true)
+		defer func() {
+			viper.Set("redis.mode", nil)
+			viper.Set("redis.addrs", nil)
+			viper.Set("cache.write.sync_invalidation", nil)
+		}()
+		ctx := context.Background()
+		c, err := cache.NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		repo := memory.NewRepositoryProvider()
+		service := books.NewBookService(repo, c)
+		book, err := repo.Book().Create(ctx, books.Book{
+			Title:       "The Dispossessed",
+			Author:      "Le Guin",
+			PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		So(err, ShouldBeNil)
+		byID := "books:books:getbyid:" + strconv.FormatInt(book.ID, 10)
+		// The keys live in different slots of the cluster
+		So(cachetest.Slot(byID), ShouldNotEqual, cachetest.Slot(books.ListVersionKey))
+		So(c.Set(ctx, byID, book, time.Minute), ShouldBeNil)
+
+		book.Title = "The Dispossessed: An Ambiguous Utopia"
+		_, err = service.Update(ctx, book.ID, *book)
+		So(err, ShouldBeNil)
+
+		version, err := c.Counter(ctx, books.ListVersionKey)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, 1)
+		_, cached := cluster.Get(byID)
+		So(cached, ShouldBeFalse)
+		So(c.Stats().Failed, ShouldEqual, 0)
+	})
+}
+
+func Test_EvictPrefix(t *testing.T) {
+	Convey("Evict cached entries by prefix", t, func() {
+		server := cachetest.NewCluster()
+		defer server.Close()
+		viper.Set("redis.mode", cache.ModeSingle)
+		viper.Set("redis.dsn", server.Addr())
+		defer viper.Set("redis.mode", nil)
+		defer viper.Set("redis.dsn", nil)
+
+		ctx := context.Background()
+		c, err := cache.NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+		service := books.NewBookService(memory.NewRepositoryProvider(), c)
+
+		So(c.Incr(ctx, books.ListVersionKey), ShouldBeNil)
+		So(c.Set(ctx, popularBooksKey, "1", time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getall:v0:all", []books.Book{}, time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getall:v1:all", []books.Book{}, time.Minute), ShouldBeNil)
+		So(c.Set(ctx, "books:books:getbyid:1", books.Book{ID: 1}, time.Minute), ShouldBeNil)
+
+		Convey("Keep the list version when the prefix matches it", func() {
+			deleted, err := service.EvictPrefix(ctx, "books:books:getall:v")
+			So(err, ShouldBeNil)
+			So(deleted, ShouldEqual, 2)
+
+			version, err := c.Counter(ctx, books.ListVersionKey)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 1)
+		})
+
+		Convey("Keep the list version and the request counts when evicting everything", func() {
+			deleted, err := service.EvictPrefix(ctx, "books:*")
+			So(err, ShouldBeNil)
+			So(deleted, ShouldEqual, 3)
+
+			_, ok := server.Get(books.ListVersionKey)
+			So(ok, ShouldBeTrue)
+			_, ok = server.Get(popularBooksKey)
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
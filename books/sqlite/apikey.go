@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/books/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// APIKeyRepository contains all methods to access the api_keys table.
+type APIKeyRepository struct {
+	db sqlx.ExtContext
+}
+
+// NewAPIKeyRepository returns a new APIKeyRepository.
+func NewAPIKeyRepository(db sqlx.ExtContext) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create stores a new API key and returns it with its ID.
+func (r *APIKeyRepository) Create(ctx context.Context, key auth.APIKey) (*auth.APIKey, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO api_keys (
+			name,
+			prefix,
+			hash,
+			role,
+			createdAt
+		) VALUES (?, ?, ?, ?, ?)
+	`, key.Name, key.Prefix, key.Hash, string(key.Role), key.CreatedAt.UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, auth.ErrKeyAlreadyExists
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	key.ID = id
+	return &key, nil
+}
+
+// GetByPrefix retrieves an API key, revoked or not, by its prefix.
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error) {
+	var key auth.APIKey
+	err := sqlx.GetContext(ctx, r.db, &key, `
+		SELECT
+			id,
+			name,
+			prefix,
+			hash,
+			role,
+			createdAt,
+			revokedAt
+		FROM api_keys
+		WHERE prefix = ?
+	`, prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auth.ErrKeyNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &key, nil
+}
+
+// List retrieves every API key ordered by ID.
+func (r *APIKeyRepository) List(ctx context.Context) ([]auth.APIKey, error) {
+	keys := []auth.APIKey{}
+	err := sqlx.SelectContext(ctx, r.db, &keys, `
+		SELECT
+			id,
+			name,
+			prefix,
+			hash,
+			role,
+			createdAt,
+			revokedAt
+		FROM api_keys
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return keys, nil
+}
+
+// Revoke sets revokedAt on a key that is not revoked yet.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET
+			revokedAt = ?
+		WHERE id = ?
+		AND revokedAt IS NULL
+	`, at.UTC(), id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if affected == 0 {
+		// Tell keys revoked before from unknown ones
+		var exists bool
+		err := sqlx.GetContext(ctx, r.db, &exists, `SELECT EXISTS (SELECT 1 FROM api_keys WHERE id = ?)`, id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if exists {
+			return auth.ErrKeyRevoked
+		}
+		return auth.ErrKeyNotFound
+	}
+
+	return nil
+}
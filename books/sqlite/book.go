@@ -0,0 +1,226 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/books/books"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	driver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// BookRepository contains all methods to access the books table.
+type BookRepository struct {
+	db sqlx.ExtContext
+}
+
+// NewBookRepository returns a new BookRepository. db is either a *sqlx.DB or
+// a *sqlx.Tx.
+func NewBookRepository(db sqlx.ExtContext) *BookRepository {
+	return &BookRepository{db: db}
+}
+
+// Create creates a new book in the database.
+func (r *BookRepository) Create(ctx context.Context, book books.Book) (*books.Book, error) {
+	result, err := sqlx.NamedExecContext(ctx, r.db, `
+		INSERT INTO books (
+			title,
+			author,
+			isbn,
+			description,
+			publishedAt,
+			createdAt,
+			updatedAt
+		) VALUES (
+			:title,
+			:author,
+			:isbn,
+			:description,
+			:publishedAt,
+			:createdAt,
+			:updatedAt
+		)
+	`, map[string]interface{}{
+		"title":       book.Title,
+		"author":      book.Author,
+		"isbn":        book.ISBN,
+		"description": book.Description,
+		"publishedAt": book.PublishedAt.UTC(),
+		"createdAt":   book.CreatedAt.UTC(),
+		"updatedAt":   book.UpdatedAt.UTC(),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, books.ErrBookAlreadyExists
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	book.ID = id
+	return &book, nil
+}
+
+// GetByID retrieves a book by its ID.
+func (r *BookRepository) GetByID(ctx context.Context, id int64) (*books.Book, error) {
+	var book books.Book
+	err := sqlx.GetContext(ctx, r.db, &book, `
+		SELECT
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			publishedAt,
+			createdAt,
+			updatedAt,
+			deletedAt
+		FROM books
+		WHERE id = ?
+		AND deletedAt IS NULL
+	`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, books.ErrBookNotFound
+		}
+		return nil, errors.WithStack(err)
+	}
+	return &book, nil
+}
+
+// GetAll retrieves all books (excluding deleted ones).
+// If author is provided, filters books by that author.
+// limit and offset are used for pagination. If limit is 0, no limit is applied.
+func (r *BookRepository) GetAll(ctx context.Context, author *string, limit, offset int) ([]books.Book, error) {
+	bookList := []books.Book{}
+	query := `
+		SELECT
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			publishedAt,
+			createdAt,
+			updatedAt,
+			deletedAt
+		FROM books
+		WHERE deletedAt IS NULL
+	`
+	args := []interface{}{}
+
+	if author != nil && *author != "" {
+		query += ` AND author = ?`
+		args = append(args, *author)
+	}
+
+	query += ` ORDER BY id ASC`
+
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+
+		if offset > 0 {
+			query += ` OFFSET ?`
+			args = append(args, offset)
+		}
+	}
+
+	err := sqlx.SelectContext(ctx, r.db, &bookList, query, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// Ensure we always return a non-nil slice (empty slice instead of nil)
+	// This ensures JSON serialization produces [] instead of null
+	if bookList == nil {
+		bookList = []books.Book{}
+	}
+
+	return bookList, nil
+}
+
+// Update updates an existing book in a single conditional statement, so a
+// concurrent delete cannot slip in between the existence check and the write.
+func (r *BookRepository) Update(ctx context.Context, id int64, book books.Book) (*books.Book, error) {
+	var updatedBook books.Book
+	err := sqlx.GetContext(ctx, r.db, &updatedBook, `
+		UPDATE books
+		SET
+			title = ?,
+			author = ?,
+			isbn = ?,
+			description = ?,
+			publishedAt = ?,
+			updatedAt = ?
+		WHERE id = ?
+		AND deletedAt IS NULL
+		RETURNING
+			id,
+			title,
+			author,
+			isbn,
+			description,
+			publishedAt,
+			createdAt,
+			updatedAt,
+			deletedAt
+	`,
+		book.Title,
+		book.Author,
+		book.ISBN,
+		book.Description,
+		book.PublishedAt.UTC(),
+		book.UpdatedAt.UTC(),
+		id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, books.ErrBookNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, books.ErrBookAlreadyExists
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	return &updatedBook, nil
+}
+
+// Delete soft deletes a book by setting deletedAt.
+func (r *BookRepository) Delete(ctx context.Context, id int64) error {
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE books
+		SET
+			deletedAt = ?
+		WHERE id = ?
+		AND deletedAt IS NULL
+	`, now, id)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if affected == 0 {
+		return books.ErrBookNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failure.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *driver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
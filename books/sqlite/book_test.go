@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/repotest"
+)
+
+func Test_BookRepository(t *testing.T) {
+	repotest.Run(t, func() books.RepositoryProvider {
+		db, err := Open(context.Background(), ":memory:")
+		if err != nil {
+			t.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return NewRepositoryProvider(db)
+	})
+}
+
+func Test_APIKeyRepository(t *testing.T) {
+	repotest.RunKeys(t, func() auth.KeyRepository {
+		db, err := Open(context.Background(), ":memory:")
+		if err != nil {
+			t.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return NewAPIKeyRepository(db)
+	})
+}
@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/books/migrations"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+func init() {
+	// sqlx does not know the modernc driver name
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+// Open opens the SQLite database at path, creating it if needed, and applies
+// pending migrations. Use ":memory:" for a throwaway database.
+func Open(ctx context.Context, path string) (*sqlx.DB, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite", dsn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// SQLite allows a single writer; one connection avoids "database is
+	// locked" errors and keeps in-memory databases on a single connection.
+	db.SetMaxOpenConns(1)
+
+	// A SQLite database belongs to this process, so keep it up to date
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate applies the pending embedded SQLite migrations.
+func migrate(ctx context.Context, db *sqlx.DB) error {
+	migrator, err := migrations.NewEmbeddedMigrator(db, "sqlite")
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
+	return err
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/books/books"
+	"github.com/books/books/sqltx"
+	"github.com/jmoiron/sqlx"
+)
+
+// RepositoryProvider manages all repositories.
+type RepositoryProvider struct {
+	db *sqlx.DB
+	// tx is set on providers passed to WithTx callbacks.
+	tx *sqlx.Tx
+}
+
+// NewRepositoryProvider returns a new RepositoryProvider.
+func NewRepositoryProvider(db *sqlx.DB) *RepositoryProvider {
+	return &RepositoryProvider{db: db}
+}
+
+// Book returns a new BookRepository.
+func (rp *RepositoryProvider) Book() books.BookRepository {
+	if rp.tx != nil {
+		return NewBookRepository(rp.tx)
+	}
+	return NewBookRepository(rp.db)
+}
+
+// WithTx runs fn with a provider whose repositories share one transaction.
+// It is committed if fn returns nil and rolled back otherwise. Nested calls
+// join the outer transaction.
+func (rp *RepositoryProvider) WithTx(ctx context.Context, fn func(books.RepositoryProvider) error) error {
+	if rp.tx != nil {
+		return fn(rp)
+	}
+	return sqltx.Run(ctx, rp.db, func(tx *sqlx.Tx) error {
+		return fn(&RepositoryProvider{db: rp.db, tx: tx})
+	})
+}
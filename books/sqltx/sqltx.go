@@ -0,0 +1,34 @@
+// Package sqltx runs functions in database transactions for the SQL
+// repositories.
+package sqltx
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Run runs fn in a transaction that is committed if fn returns nil and
+// rolled back otherwise, including when fn panics.
+func Run(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
@@ -0,0 +1,60 @@
+package sqltx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/books/books/sqltx"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	_ "modernc.org/sqlite"
+)
+
+func Test_Run(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Run", t, func() {
+		db, err := sqlx.Open("sqlite", ":memory:")
+		So(err, ShouldBeNil)
+		db.SetMaxOpenConns(1)
+		Reset(func() { db.Close() })
+		_, err = db.Exec("CREATE TABLE counters (n INTEGER)")
+		So(err, ShouldBeNil)
+
+		insert := func(tx *sqlx.Tx) error {
+			_, err := tx.Exec("INSERT INTO counters (n) VALUES (1)")
+			return err
+		}
+		count := func() int {
+			var n int
+			So(db.Get(&n, "SELECT COUNT(*) FROM counters"), ShouldBeNil)
+			return n
+		}
+
+		Convey("Commit when fn succeeds", func() {
+			So(sqltx.Run(ctx, db, insert), ShouldBeNil)
+			So(count(), ShouldEqual, 1)
+		})
+
+		Convey("Roll back when fn fails", func() {
+			errFailed := errors.New("failed")
+			err := sqltx.Run(ctx, db, func(tx *sqlx.Tx) error {
+				So(insert(tx), ShouldBeNil)
+				return errFailed
+			})
+			So(err, ShouldEqual, errFailed)
+			So(count(), ShouldEqual, 0)
+		})
+
+		Convey("Roll back when fn panics", func() {
+			So(func() {
+				_ = sqltx.Run(ctx, db, func(tx *sqlx.Tx) error {
+					So(insert(tx), ShouldBeNil)
+					panic("boom")
+				})
+			}, ShouldPanicWith, "boom")
+			So(count(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package books
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of BookService.
+var tracer = otel.Tracer("github.com/books/books")
+
+// startSpan starts the span of a BookService method.
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "BookService."+method, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on span and ends it. Errors reported to the client,
+// such as ErrBookNotFound, do not mark the span as failed.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !isClientError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// isClientError reports whether err is caused by the request rather than by
+// the service.
+func isClientError(err error) bool {
+	return errors.Is(err, ErrBookNotFound) ||
+		errors.Is(err, ErrBookAlreadyExists) ||
+		errors.Is(err, ErrInvalidBookData)
+}
@@ -0,0 +1,166 @@
+package books
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/books/books/cache"
+	"github.com/pkg/errors"
+)
+
+// popularBooksKey is a sorted set of book IDs scored by how often they were
+// requested through GetByID.
+const popularBooksKey = "books:books:popular"
+
+// recordHit counts a request for an existing book, so that the most popular
+// books can be warmed after a deploy.
+func (s *BookService) recordHit(ctx context.Context, id int64) {
+	_ = s.cache.RecordHit(ctx, popularBooksKey, strconv.FormatInt(id, 10))
+}
+
+// WarmOptions configures BookService.Warm.
+type WarmOptions struct {
+	// TopBooks is the number of most requested books to preload.
+	TopBooks int
+	// Authors is the number of author lists to preload, starting with the
+	// authors with the most books.
+	Authors int
+	// Rate is the maximum number of database queries per second.
+	// 0 means unlimited.
+	Rate int
+}
+
+// WarmResult reports what Warm loaded into the cache.
+type WarmResult struct {
+	Lists int
+	Books int
+}
+
+// Warm preloads the cache with the all-books list, the author lists and the
+// most requested books so that a freshly deployed instance does not send a
+// burst of queries to the database.
+func (s *BookService) Warm(ctx context.Context, opts WarmOptions) (*WarmResult, error) {
+	if s.cache == nil {
+		return nil, errCacheNotConfigured
+	}
+
+	// Read from the primary, since a lagging replica could fill the cache
+	// with data older than the last invalidation
+	ctx = WithReadPin(ctx)
+	PinReads(ctx)
+
+	var limiter <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+	wait := func() error {
+		if limiter == nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-limiter:
+			return nil
+		}
+	}
+
+	result := &WarmResult{}
+
+	version, err := s.cache.Counter(ctx, listVersionKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// All books
+	if err := wait(); err != nil {
+		return result, err
+	}
+	allBooks, err := s.repo.Book().GetAll(ctx, nil, 0, 0)
+	if err != nil {
+		return result, errors.WithStack(err)
+	}
+	if err := s.cache.Set(ctx, getAllCacheKey(version, nil, 0, 0), allBooks, s.cache.TTL(cache.FamilyList)); err != nil {
+		return result, errors.WithStack(err)
+	}
+	result.Lists++
+
+	// Author lists, most prolific authors first
+	for _, author := range topAuthors(allBooks, opts.Authors) {
+		if err := wait(); err != nil {
+			return result, err
+		}
+		author := author
+		authorBooks, err := s.repo.Book().GetAll(ctx, &author, 0, 0)
+		if err != nil {
+			return result, errors.WithStack(err)
+		}
+		if err := s.cache.Set(ctx, getAllCacheKey(version, &author, 0, 0), authorBooks, s.cache.TTL(cache.FamilyList)); err != nil {
+			return result, errors.WithStack(err)
+		}
+		result.Lists++
+	}
+
+	// Most requested books
+	ids, err := s.cache.TopHits(ctx, popularBooksKey, opts.TopBooks)
+	if err != nil {
+		return result, errors.WithStack(err)
+	}
+	for _, member := range ids {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		if err := wait(); err != nil {
+			return result, err
+		}
+		book, err := s.repo.Book().GetByID(ctx, id)
+		if errors.Is(err, ErrBookNotFound) {
+			// Deleted since it was requested
+			continue
+		}
+		if err != nil {
+			return result, errors.WithStack(err)
+		}
+		if err := s.cache.Set(ctx, getByIDCacheKey(id), book, s.cache.TTL(cache.FamilyByID)); err != nil {
+			return result, errors.WithStack(err)
+		}
+		result.Books++
+	}
+
+	slog.InfoContext(ctx, "cache warmed", "lists", result.Lists, "books", result.Books)
+	return result, nil
+}
+
+// topAuthors returns up to n authors ordered by number of books.
+func topAuthors(bookList []Book, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, book := range bookList {
+		counts[book.Author]++
+	}
+
+	authors := make([]string, 0, len(counts))
+	for author := range counts {
+		authors = append(authors, author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+
+	if len(authors) > n {
+		authors = authors[:n]
+	}
+	return authors
+}
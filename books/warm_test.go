@@ -0,0 +1,125 @@
+package books_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/books/books/memory"
+	"github.com/books/testdata"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+// popularBooksKey is the sorted set of requested book IDs.
+const popularBooksKey = "books:books:popular"
+
+func Test_TopAuthors(t *testing.T) {
+	Convey("topAuthors", t, func() {
+		bookList := []books.Book{
+			{Author: "Le Guin"}, {Author: "Banks"}, {Author: "Le Guin"},
+			{Author: "Asimov"}, {Author: "Banks"}, {Author: "Le Guin"},
+		}
+
+		So(books.TopAuthors(bookList, 2), ShouldResemble, []string{"Le Guin", "Banks"})
+		// Ties are broken by name
+		So(books.TopAuthors(bookList[:2], 5), ShouldResemble, []string{"Banks", "Le Guin"})
+		So(books.TopAuthors(bookList, 0), ShouldBeNil)
+		So(books.TopAuthors(nil, 3), ShouldBeEmpty)
+	})
+}
+
+func Test_Warm(t *testing.T) {
+	suite := testdata.NewSuite(t).
+		WithCache()
+	defer suite.Close()
+	ctx := context.Background()
+
+	Convey("Warm without a cache", t, func() {
+		repo := memory.NewRepositoryProvider()
+
+		_, err := books.NewBookService(repo, nil).Warm(ctx, books.WarmOptions{})
+		So(err, ShouldNotBeNil)
+
+		_, err = books.NewBookService(repo, suite.UnavailableCache()).Warm(ctx, books.WarmOptions{})
+		So(errors.Is(err, cache.ErrUnavailable), ShouldBeTrue)
+	})
+
+	live := Convey
+	if suite.Cache() == nil {
+		live = SkipConvey
+	}
+	live("Warm", t, func() {
+		So(suite.Cache().FlushDB(ctx), ShouldBeNil)
+		// A cache of its own, drained to wait for the recorded hits
+		c, err := cache.NewCache()
+		So(err, ShouldBeNil)
+		defer c.Close(ctx)
+
+		repo := memory.NewRepositoryProvider()
+		service := books.NewBookService(repo, c)
+		create := func(title, author string) int64 {
+			book, err := repo.Book().Create(ctx, books.Book{
+				Title:       title,
+				Author:      author,
+				PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			})
+			So(err, ShouldBeNil)
+			return book.ID
+		}
+		popular := create("The Dispossessed", "Le Guin")
+		create("The Lathe of Heaven", "Le Guin")
+		other := create("Excession", "Banks")
+
+		for _, id := range []int64{popular, popular, other, 999} {
+			_, _ = service.GetByID(ctx, id)
+		}
+		So(c.Drain(ctx), ShouldBeNil)
+
+		Convey("Count the requests of existing books only", func() {
+			ids, err := c.TopHits(ctx, popularBooksKey, 10)
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []string{strconv.FormatInt(popular, 10), strconv.FormatInt(other, 10)})
+		})
+
+		Convey("Preload the lists and the most requested books", func() {
+			So(c.Delete(ctx, "books:books:getbyid:"+strconv.FormatInt(popular, 10)), ShouldBeNil)
+
+			result, err := service.Warm(ctx, books.WarmOptions{TopBooks: 1, Authors: 1})
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &books.WarmResult{Lists: 2, Books: 1})
+
+			var cached []books.Book
+			So(c.Get(ctx, "books:books:getall:v0:all", &cached), ShouldBeNil)
+			So(cached, ShouldHaveLength, 3)
+			So(c.Get(ctx, "books:books:getall:v0:author=Le+Guin", &cached), ShouldBeNil)
+			So(cached, ShouldHaveLength, 2)
+			var book books.Book
+			So(c.Get(ctx, "books:books:getbyid:"+strconv.FormatInt(popular, 10), &book), ShouldBeNil)
+			So(book.ID, ShouldEqual, popular)
+		})
+
+		Convey("Keep the most requested books up to cache.warm.popular_size", func() {
+			viper.Set("cache.warm.popular_size", 2)
+			defer viper.Set("cache.warm.popular_size", nil)
+			bounded, err := cache.NewCache()
+			So(err, ShouldBeNil)
+			defer bounded.Close(ctx)
+			So(c.Delete(ctx, popularBooksKey), ShouldBeNil)
+
+			for _, member := range []string{"1", "1", "2", "2", "3"} {
+				So(bounded.RecordHit(ctx, popularBooksKey, member), ShouldBeNil)
+			}
+			So(bounded.Drain(ctx), ShouldBeNil)
+
+			ids, err := c.TopHits(ctx, popularBooksKey, 10)
+			So(err, ShouldBeNil)
+			So(ids, ShouldHaveLength, 2)
+			So(ids, ShouldNotContain, "3")
+		})
+	})
+}
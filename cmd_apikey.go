@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/books/auth"
+)
+
+// apikeyCommand runs the API key subcommands:
+//
+//	books apikey create -name NAME [-role reader|editor|admin]
+//	books apikey revoke ID
+//	books apikey list
+func apikeyCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: books apikey create|revoke|list")
+	}
+
+	driver, db := openDB()
+	defer db.Close()
+
+	store := &storage{driver: driver, db: db}
+	keys := store.APIKeys()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		apikeyCreate(ctx, keys, args[1:])
+	case "revoke":
+		if len(args) < 2 {
+			log.Fatal("Usage: books apikey revoke ID")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid API key ID %q", args[1])
+		}
+		err = keys.Revoke(ctx, id, time.Now().UTC())
+		if errors.Is(err, auth.ErrKeyRevoked) {
+			log.Printf("API key %d was already revoked", id)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to revoke API key %d: %v", id, err)
+			os.Exit(1)
+		}
+		log.Printf("Revoked API key %d", id)
+	case "list":
+		apikeyList(ctx, keys)
+	default:
+		log.Fatalf("Unknown apikey command %q (expected create, revoke or list)", args[0])
+	}
+}
+
+// apikeyCreate stores a new API key and prints it. Only its hash is stored,
+// so this is the only time it is shown.
+func apikeyCreate(ctx context.Context, keys auth.KeyRepository, args []string) {
+	fs := flag.NewFlagSet("apikey create", flag.ExitOnError)
+	name := fs.String("name", "", "who or what the key is for")
+	roleName := fs.String("role", string(auth.RoleReader), "reader, editor or admin")
+	_ = fs.Parse(args)
+
+	if *name == "" {
+		log.Fatal("Usage: books apikey create -name NAME [-role reader|editor|admin]")
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		log.Fatalf("Invalid -role: %v", err)
+	}
+
+	secret, key, err := auth.GenerateKey(*name, role)
+	if err != nil {
+		log.Fatalf("Failed to generate API key: %v", err)
+	}
+	created, err := keys.Create(ctx, key)
+	if err != nil {
+		log.Printf("Failed to store API key: %v", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Created API key %d (%s) with the %s role; store it now, it cannot be shown again", created.ID, created.Prefix, created.Role)
+	fmt.Println(secret)
+}
+
+// apikeyList prints every API key without its secret.
+func apikeyList(ctx context.Context, keys auth.KeyRepository) {
+	list, err := keys.List(ctx)
+	if err != nil {
+		log.Printf("Failed to list API keys: %v", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREFIX\tNAME\tROLE\tCREATED AT\tREVOKED AT")
+	for _, key := range list {
+		revokedAt := "-"
+		if key.RevokedAt != nil {
+			revokedAt = key.RevokedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Prefix, key.Name, key.Role, key.CreatedAt.UTC().Format(time.RFC3339), revokedAt)
+	}
+	w.Flush()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/books/books"
+	"github.com/books/books/cache"
+	"github.com/spf13/viper"
+)
+
+// cacheCommand runs the cache subcommands:
+//
+//	books cache warm [-top N] [-authors N] [-rate N]
+func cacheCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: books cache warm [-top N] [-authors N] [-rate N]")
+	}
+
+	switch args[0] {
+	case "warm":
+		cacheWarm(args[1:])
+	default:
+		log.Fatalf("Unknown cache command %q (expected warm)", args[0])
+	}
+}
+
+// cacheWarm preloads the cache and exits.
+func cacheWarm(args []string) {
+	opts := warmOptions()
+
+	fs := flag.NewFlagSet("cache warm", flag.ExitOnError)
+	fs.IntVar(&opts.TopBooks, "top", opts.TopBooks, "number of most requested books to preload")
+	fs.IntVar(&opts.Authors, "authors", opts.Authors, "number of author lists to preload")
+	fs.IntVar(&opts.Rate, "rate", opts.Rate, "maximum database queries per second (0 for unlimited)")
+	_ = fs.Parse(args)
+
+	repoProvider, store := openStorage()
+	defer store.Close()
+
+	redisCache, err := cache.NewCache()
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis cache: %v", err)
+	}
+	defer redisCache.Close(context.Background())
+
+	service := books.NewBookService(repoProvider, redisCache)
+	result, err := service.Warm(context.Background(), opts)
+	if err != nil {
+		log.Printf("Failed to warm cache: %v", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Warmed %d lists and %d books", result.Lists, result.Books)
+}
+
+// warmOptions reads the cache.warm config section.
+func warmOptions() books.WarmOptions {
+	opts := books.WarmOptions{
+		TopBooks: 100,
+		Authors:  50,
+		Rate:     20,
+	}
+	if viper.IsSet("cache.warm.top_books") {
+		opts.TopBooks = viper.GetInt("cache.warm.top_books")
+	}
+	if viper.IsSet("cache.warm.authors") {
+		opts.Authors = viper.GetInt("cache.warm.authors")
+	}
+	if viper.IsSet("cache.warm.rate") {
+		opts.Rate = viper.GetInt("cache.warm.rate")
+	}
+	return opts
+}
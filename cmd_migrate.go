@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/books/migrations"
+)
+
+// migrateCommand runs the migration subcommands:
+//
+//	books migrate up|down|status|to N
+func migrateCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: books migrate up|down|status|to N")
+	}
+
+	driver, db := openDB()
+	defer db.Close()
+
+	migrator := newMigrator(driver, db)
+	ctx := context.Background()
+
+	var changed []int
+	var err error
+	switch args[0] {
+	case "up":
+		changed, err = migrator.Up(ctx)
+	case "down":
+		changed, err = migrator.Down(ctx)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal("Usage: books migrate to N")
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			log.Fatalf("Invalid migration version %q", args[1])
+		}
+		changed, err = migrator.To(ctx, version)
+	case "status":
+		migrateStatus(ctx, migrator)
+		return
+	default:
+		log.Fatalf("Unknown migrate command %q (expected up, down, status or to)", args[0])
+	}
+
+	if err != nil {
+		log.Printf("Failed to migrate database: %v", err)
+		os.Exit(1)
+	}
+
+	if len(changed) == 0 {
+		log.Print("Database is up to date")
+		return
+	}
+	log.Printf("Migrated %d version(s): %v", len(changed), changed)
+}
+
+// migrateStatus prints every migration with its state.
+func migrateStatus(ctx context.Context, migrator *migrations.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Printf("Failed to read migration status: %v", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+	}
+	w.Flush()
+}
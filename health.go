@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/books/books/cache"
+	"github.com/books/health"
+	"github.com/spf13/viper"
+)
+
+// newHealthChecker returns the readiness checks of the server. The components
+// listed in health.critical fail readiness when down; the others only degrade
+// it.
+func newHealthChecker(store *storage, redisCache *cache.Cache) *health.Checker {
+	critical := map[string]bool{"database": true, "migrations": true}
+	if viper.IsSet("health.critical") {
+		critical = make(map[string]bool)
+		for _, name := range viper.GetStringSlice("health.critical") {
+			critical[name] = true
+		}
+	}
+
+	checker := health.NewChecker(viper.GetDuration("health.timeout"))
+
+	checker.Add("database", critical["database"], func(ctx context.Context) error {
+		return store.db.PingContext(ctx)
+	})
+
+	migrator := newMigrator(store.driver, store.db)
+	checker.Add("migrations", critical["migrations"], func(ctx context.Context) error {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if version != migrator.Latest() {
+			return fmt.Errorf("schema is at version %d, expected %d", version, migrator.Latest())
+		}
+		return nil
+	})
+
+	checker.Add("cache", critical["cache"], redisCache.Ping)
+
+	if store.replicas != nil {
+		checker.Add("replicas", critical["replicas"], func(ctx context.Context) error {
+			if store.replicas.Healthy() == 0 {
+				return fmt.Errorf("no healthy read replica")
+			}
+			return nil
+		})
+	}
+
+	return checker
+}
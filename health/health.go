@@ -0,0 +1,158 @@
+// Package health serves liveness and readiness probes that check the
+// service's dependencies.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Overall statuses.
+const (
+	StatusOK           = "ok"
+	StatusDegraded     = "degraded"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting down"
+)
+
+// Component statuses.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// defaultTimeout bounds each check when the Checker has no timeout.
+const defaultTimeout = 2 * time.Second
+
+// check is a registered dependency check.
+type check struct {
+	name     string
+	critical bool
+	fn       func(ctx context.Context) error
+}
+
+// Component is the result of one check.
+type Component struct {
+	Status string `json:"status"`
+	// Critical components fail readiness when down; others only degrade it.
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness report.
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+}
+
+// NewChecker returns a Checker that gives every check up to timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check. When a critical check fails the service is not ready;
+// when another one fails it is ready but degraded.
+func (h *Checker) Add(name string, critical bool, fn func(ctx context.Context) error) {
+	h.checks = append(h.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Drain makes readiness fail from now on, so that load balancers stop
+// sending requests before the server shuts down.
+func (h *Checker) Drain() {
+	h.draining.Store(true)
+}
+
+// Check runs every check concurrently and returns the report.
+func (h *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]Component, len(h.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.fn(checkCtx)
+			component := Component{
+				Status:    StatusUp,
+				Critical:  c.critical,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				component.Status = StatusDown
+				component.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Components[c.name] = component
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		if component.Status == StatusUp {
+			continue
+		}
+		if component.Critical {
+			report.Status = StatusFail
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	if h.draining.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// Routes registers the probes:
+//
+//	GET /health/live  - 200 while the process is serving requests
+//	GET /health/ready - 200 when ok or degraded, 503 when a critical
+//	                    dependency is down or the server is shutting down
+//	GET /health       - same as /health/ready
+func (h *Checker) Routes(e *echo.Echo) {
+	e.GET("/health/live", h.live)
+	e.GET("/health/ready", h.ready)
+	e.GET("/health", h.ready)
+}
+
+// live reports that the process is up. It checks no dependency, so that an
+// orchestrator does not restart the service because the database is down.
+func (h *Checker) live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// ready reports whether the service should receive traffic.
+func (h *Checker) ready(c echo.Context) error {
+	report := h.Check(c.Request().Context())
+
+	code := http.StatusOK
+	if report.Status == StatusFail || report.Status == StatusShuttingDown {
+		code = http.StatusServiceUnavailable
+	}
+	return c.JSON(code, report)
+}
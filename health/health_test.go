@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Checker(t *testing.T) {
+	Convey("Checker", t, func() {
+		e := echo.New()
+		checker := NewChecker(50 * time.Millisecond)
+		checker.Routes(e)
+
+		get := func(path string) (int, Report) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			var report Report
+			So(json.Unmarshal(rec.Body.Bytes(), &report), ShouldBeNil)
+			return rec.Code, report
+		}
+
+		up := func(context.Context) error { return nil }
+		down := func(context.Context) error { return errors.New("connection refused") }
+
+		Convey("Report ok when every check passes", func() {
+			checker.Add("database", true, up)
+			checker.Add("cache", false, up)
+
+			code, report := get("/health/ready")
+			So(code, ShouldEqual, http.StatusOK)
+			So(report.Status, ShouldEqual, StatusOK)
+			So(report.Components["database"].Status, ShouldEqual, StatusUp)
+			So(report.Components["database"].Critical, ShouldBeTrue)
+		})
+
+		Convey("Degrade when a non-critical check fails", func() {
+			checker.Add("database", true, up)
+			checker.Add("cache", false, down)
+
+			code, report := get("/health/ready")
+			So(code, ShouldEqual, http.StatusOK)
+			So(report.Status, ShouldEqual, StatusDegraded)
+			So(report.Components["cache"].Error, ShouldEqual, "connection refused")
+		})
+
+		Convey("Fail when a critical check fails", func() {
+			checker.Add("database", true, down)
+			checker.Add("cache", false, down)
+
+			code, report := get("/health")
+			So(code, ShouldEqual, http.StatusServiceUnavailable)
+			So(report.Status, ShouldEqual, StatusFail)
+		})
+
+		Convey("Time out slow checks", func() {
+			checker.Add("database", true, func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			code, report := get("/health/ready")
+			So(code, ShouldEqual, http.StatusServiceUnavailable)
+			So(report.Components["database"].Status, ShouldEqual, StatusDown)
+		})
+
+		Convey("Fail readiness but not liveness while draining", func() {
+			checker.Add("database", true, down)
+			checker.Drain()
+
+			code, report := get("/health/ready")
+			So(code, ShouldEqual, http.StatusServiceUnavailable)
+			So(report.Status, ShouldEqual, StatusShuttingDown)
+
+			code, report = get("/health/live")
+			So(code, ShouldEqual, http.StatusOK)
+			So(report.Status, ShouldEqual, StatusOK)
+		})
+	})
+}
@@ -0,0 +1,181 @@
+// Package i18n translates the messages of the API. Messages live in one JSON
+// catalog per language under locales/, embedded in the binary, so that
+// translations can be added without changing Go code.
+//
+// A catalog maps sections to keys to messages:
+//
+//	{
+//	  "validation": {"max_length": "{field} must be at most {max} characters"},
+//	  "fields": {"title": "title"}
+//	}
+//
+// Messages may contain {name} placeholders, replaced by the parameters given
+// to Message.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+)
+
+// Sections of the catalogs.
+const (
+	// SectionValidation holds field error messages by validation code.
+	SectionValidation = "validation"
+	// SectionFields holds field names by JSON field name.
+	SectionFields = "fields"
+	// SectionProblems holds problem titles by problem type, e.g.
+	// book-not-found.
+	SectionProblems = "problems"
+	// SectionHTTP holds the titles of plain HTTP errors by status code.
+	SectionHTTP = "http"
+	// SectionDetails holds problem details by their English text.
+	SectionDetails = "details"
+)
+
+// Default is the language used when no requested language is supported, and
+// for the messages missing from another catalog.
+const Default = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Catalog holds the messages of a language.
+type Catalog struct {
+	lang     string
+	messages map[string]map[string]string
+	// fallback is the catalog of the default language, nil for itself.
+	fallback *Catalog
+}
+
+// Bundle holds the catalogs of every supported language.
+type Bundle struct {
+	catalogs map[string]*Catalog
+	// tags are the supported languages, the default first; langs are the
+	// catalog names in the same order.
+	tags    []language.Tag
+	langs   []string
+	matcher language.Matcher
+}
+
+// defaultBundle holds the embedded catalogs.
+var defaultBundle = mustLoad(locales)
+
+// Load reads the catalogs named <lang>.json in the locales directory of fsys.
+// A catalog for the default language is required.
+func Load(fsys fs.FS) (*Bundle, error) {
+	files, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Strings(files)
+
+	b := &Bundle{catalogs: make(map[string]*Catalog, len(files))}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		lang := strings.TrimSuffix(path.Base(file), ".json")
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, errors.Wrapf(err, "catalog %s", file)
+		}
+
+		c := &Catalog{lang: lang}
+		if err := json.Unmarshal(data, &c.messages); err != nil {
+			return nil, errors.Wrapf(err, "catalog %s", file)
+		}
+		b.catalogs[lang] = c
+
+		// The first tag is the matcher's default
+		if lang == Default {
+			b.tags = append([]language.Tag{tag}, b.tags...)
+			b.langs = append([]string{lang}, b.langs...)
+		} else {
+			b.tags = append(b.tags, tag)
+			b.langs = append(b.langs, lang)
+		}
+	}
+
+	fallback, ok := b.catalogs[Default]
+	if !ok {
+		return nil, errors.Errorf("no catalog for the default language %q", Default)
+	}
+	for lang, c := range b.catalogs {
+		if lang != Default {
+			c.fallback = fallback
+		}
+	}
+	b.matcher = language.NewMatcher(b.tags)
+
+	return b, nil
+}
+
+// mustLoad loads the embedded catalogs, which are checked by the tests.
+func mustLoad(fsys fs.FS) *Bundle {
+	b, err := Load(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Negotiate returns the catalog that best matches an Accept-Language header,
+// e.g. fr for "fr-CA, en;q=0.5". It returns the default catalog when no
+// requested language is supported.
+func Negotiate(acceptLanguage string) *Catalog {
+	return defaultBundle.Negotiate(acceptLanguage)
+}
+
+// Negotiate returns the catalog of b that best matches an Accept-Language
+// header.
+func (b *Bundle) Negotiate(acceptLanguage string) *Catalog {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return b.catalogs[Default]
+	}
+
+	_, index, confidence := b.matcher.Match(tags...)
+	if confidence == language.No {
+		return b.catalogs[Default]
+	}
+	return b.catalogs[b.langs[index]]
+}
+
+// Lang returns the language of the catalog, e.g. fr.
+func (c *Catalog) Lang() string {
+	return c.lang
+}
+
+// Message returns the message for key in section with its placeholders
+// replaced by params. Messages missing from the catalog are looked up in the
+// default one. ok is false when no catalog has the message.
+func (c *Catalog) Message(section, key string, params map[string]string) (message string, ok bool) {
+	for cat := c; cat != nil; cat = cat.fallback {
+		if message, ok = cat.messages[section][key]; ok {
+			return interpolate(message, params), true
+		}
+	}
+	return "", false
+}
+
+// interpolate replaces the {name} placeholders of message by params.
+func interpolate(message string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(message, "{") {
+		return message
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
@@ -0,0 +1,72 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Catalogs(t *testing.T) {
+	Convey("Embedded catalogs", t, func() {
+		english := defaultBundle.catalogs[Default]
+		placeholder := regexp.MustCompile(`\{\w+\}`)
+
+		Convey("Only translate messages of the default catalog, with its placeholders", func() {
+			for _, c := range defaultBundle.catalogs {
+				for section, messages := range c.messages {
+					for key, message := range messages {
+						original, ok := english.messages[section][key]
+						So(ok, ShouldBeTrue)
+						for _, name := range placeholder.FindAllString(message, -1) {
+							So(original, ShouldContainSubstring, name)
+						}
+					}
+				}
+			}
+		})
+
+		Convey("Negotiate the best supported language", func() {
+			So(Negotiate("fr-CA, en;q=0.5").Lang(), ShouldEqual, "fr")
+			So(Negotiate("de-AT").Lang(), ShouldEqual, "de")
+			So(Negotiate("es, de;q=0.3").Lang(), ShouldEqual, "de")
+			So(Negotiate("es").Lang(), ShouldEqual, Default)
+			So(Negotiate("").Lang(), ShouldEqual, Default)
+			So(Negotiate("not a language!").Lang(), ShouldEqual, Default)
+		})
+
+		Convey("Interpolate parameters", func() {
+			message, ok := Negotiate("fr").Message(SectionValidation, "max_length", map[string]string{"field": "titre", "max": "255"})
+			So(ok, ShouldBeTrue)
+			So(message, ShouldEqual, "Le champ titre doit contenir au plus 255 caractères")
+		})
+	})
+
+	Convey("Fall back to the default language", t, func() {
+		bundle, err := Load(fstest.MapFS{
+			"locales/en.json": {Data: []byte(`{"problems": {"book-not-found": "Book not found", "internal-error": "Internal server error"}}`)},
+			"locales/fr.json": {Data: []byte(`{"problems": {"book-not-found": "Livre introuvable"}}`)},
+		})
+		So(err, ShouldBeNil)
+		french := bundle.Negotiate("fr")
+
+		message, ok := french.Message(SectionProblems, "book-not-found", nil)
+		So(ok, ShouldBeTrue)
+		So(message, ShouldEqual, "Livre introuvable")
+
+		message, ok = french.Message(SectionProblems, "internal-error", nil)
+		So(ok, ShouldBeTrue)
+		So(message, ShouldEqual, "Internal server error")
+
+		_, ok = french.Message(SectionProblems, "unknown", nil)
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Require a catalog for the default language", t, func() {
+		_, err := Load(fstest.MapFS{
+			"locales/fr.json": {Data: []byte(`{}`)},
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
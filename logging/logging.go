@@ -0,0 +1,199 @@
+// Package logging sets up structured logging with log/slog and tags the logs
+// of a request with its request ID.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats selected by log.format.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// maxRequestIDLength bounds inbound request IDs, which end up in every log
+// line of the request.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// Setup makes slog write to w with the level in log.level (debug, info, warn
+// or error; info by default) and the format in log.format (json by default,
+// or text). Output of the log package goes through the same handler at the
+// error level.
+func Setup(w io.Writer) error {
+	level := slog.LevelInfo
+	if name := viper.GetString("log.level"); name != "" {
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return errors.Errorf("unknown log level %q", name)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := viper.GetString("log.format"); format {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return errors.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	// What is still logged with the log package is fatal
+	slog.SetLogLoggerLevel(slog.LevelError)
+	return nil
+}
+
+// contextHandler adds the request ID and trace of the context to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request_id, trace_id and span_id attributes found in ctx.
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler whose records also have attrs.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler that puts the attributes added later in a group.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Stack returns the stack trace recorded by github.com/pkg/errors at the
+// origin of err, or "" if there is none.
+func Stack(err error) string {
+	type stackTracer interface {
+		StackTrace() errors.StackTrace
+	}
+
+	// The innermost stack is the closest to where the error happened
+	var origin stackTracer
+	for ; err != nil; err = errors.Unwrap(err) {
+		if st, ok := err.(stackTracer); ok {
+			origin = st
+		}
+	}
+	if origin == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%+v", origin.StackTrace()))
+}
+
+// Middleware gives every request an ID and logs it once it has been served.
+// The ID comes from the X-Request-ID header when the client sends a valid one
+// and is generated otherwise. It is returned in the X-Request-ID response
+// header and added to every log written with the request's context. Errors
+// are answered before logging and still returned, so that outer middleware
+// such as tracing sees them.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			ctx := WithRequestID(req.Context(), id)
+			c.SetRequest(req.WithContext(ctx))
+
+			// Write the error response now, so that its status is logged
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			slog.LogAttrs(ctx, slog.LevelInfo, "request",
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", res.Status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes_out", res.Size),
+				slog.String("remote_ip", c.RealIP()),
+				slog.String("user_agent", req.UserAgent()),
+			)
+			return err
+		}
+	}
+}
+
+// ResponseStatus returns the status of the response to c, given the error
+// returned by the handler. Until the error handler has written the response,
+// it is the status the error will be answered with.
+func ResponseStatus(c echo.Context, err error) int {
+	res := c.Response()
+	if err == nil || res.Committed {
+		return res.Status
+	}
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// validRequestID reports whether id is short printable ASCII, so that clients
+// cannot inject arbitrary content into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex ID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
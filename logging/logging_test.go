@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+)
+
+func Test_Logging(t *testing.T) {
+	Convey("Logging", t, func() {
+		defaultLogger := slog.Default()
+		defer slog.SetDefault(defaultLogger)
+
+		var out bytes.Buffer
+		viper.Set("log.level", "debug")
+		defer viper.Set("log.level", "")
+		So(Setup(&out), ShouldBeNil)
+
+		e := echo.New()
+		// outerErr is the error seen by middleware running before Middleware
+		var outerErr error
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				outerErr = next(c)
+				return outerErr
+			}
+		})
+		e.Use(Middleware())
+		e.GET("/books/:id", func(c echo.Context) error {
+			slog.InfoContext(c.Request().Context(), "looking up book")
+			if c.Param("id") == "0" {
+				return echo.NewHTTPError(http.StatusNotFound, "book not found")
+			}
+			return c.String(http.StatusOK, "book")
+		})
+
+		get := func(path, requestID string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if requestID != "" {
+				req.Header.Set(echo.HeaderXRequestID, requestID)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			return rec
+		}
+
+		records := func() []map[string]interface{} {
+			var result []map[string]interface{}
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				var record map[string]interface{}
+				So(json.Unmarshal([]byte(line), &record), ShouldBeNil)
+				result = append(result, record)
+			}
+			return result
+		}
+
+		Convey("Tag the logs of a request with the inbound request ID", func() {
+			rec := get("/books/1", "req-42")
+			So(rec.Header().Get(echo.HeaderXRequestID), ShouldEqual, "req-42")
+
+			logs := records()
+			So(logs, ShouldHaveLength, 2)
+			So(logs[0]["msg"], ShouldEqual, "looking up book")
+			So(logs[0]["request_id"], ShouldEqual, "req-42")
+			So(logs[1]["msg"], ShouldEqual, "request")
+			So(logs[1]["request_id"], ShouldEqual, "req-42")
+			So(logs[1]["route"], ShouldEqual, "/books/:id")
+			So(logs[1]["status"], ShouldEqual, 200)
+		})
+
+		Convey("Generate a request ID when the inbound one is missing or invalid", func() {
+			rec := get("/books/1", "")
+			So(rec.Header().Get(echo.HeaderXRequestID), ShouldHaveLength, 32)
+
+			rec = get("/books/1", "bad id\n")
+			So(rec.Header().Get(echo.HeaderXRequestID), ShouldHaveLength, 32)
+		})
+
+		Convey("Log the status of error responses", func() {
+			rec := get("/books/0", "")
+			So(rec.Code, ShouldEqual, http.StatusNotFound)
+
+			logs := records()
+			So(logs[len(logs)-1]["status"], ShouldEqual, 404)
+		})
+
+		Convey("Pass errors on to outer middleware after answering them", func() {
+			rec := get("/books/0", "")
+			So(outerErr, ShouldNotBeNil)
+			So(rec.Body.String(), ShouldEqual, "{\"message\":\"book not found\"}\n")
+		})
+
+		Convey("Reject an unknown level", func() {
+			viper.Set("log.level", "verbose")
+			So(Setup(&out), ShouldNotBeNil)
+		})
+	})
+}
+
+func Test_ResponseStatus(t *testing.T) {
+	Convey("ResponseStatus", t, func() {
+		e := echo.New()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+		So(ResponseStatus(c, echo.NewHTTPError(http.StatusNotFound)), ShouldEqual, http.StatusNotFound)
+		So(ResponseStatus(c, errors.New("connection refused")), ShouldEqual, http.StatusInternalServerError)
+
+		// Once the error is answered, the response has the last word
+		So(c.NoContent(http.StatusServiceUnavailable), ShouldBeNil)
+		So(ResponseStatus(c, errors.New("cache unavailable")), ShouldEqual, http.StatusServiceUnavailable)
+		So(ResponseStatus(c, nil), ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
+
+func Test_Stack(t *testing.T) {
+	Convey("Stack returns the stack of the error's origin", t, func() {
+		err := errors.Wrap(origin(), "get book")
+		So(Stack(err), ShouldStartWith, "github.com/books/logging.origin")
+		So(Stack(errors.Cause(err)), ShouldEqual, Stack(err))
+		So(Stack(nil), ShouldEqual, "")
+	})
+}
+
+func origin() error {
+	return errors.New("connection refused")
+}
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/books/auth"
+	"github.com/books/books"
 	"github.com/books/books/api"
 	"github.com/books/books/cache"
 	"github.com/books/config"
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/jmoiron/sqlx"
+	"github.com/books/logging"
+	"github.com/books/metrics"
+	"github.com/books/tracing"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/viper"
@@ -22,54 +32,180 @@ func main() {
 		log.Fatalf("Failed to initialize config: %v", err)
 	}
 
-	// Setup database
-	host := viper.GetString("db.host")
-	user := viper.GetString("db.user")
-	password := viper.GetString("db.password")
-	databaseName := viper.GetString("db.database")
-	port := viper.GetInt64("db.port")
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", user, password, host, port, databaseName)
-
-	db, err := sqlx.Connect("mysql", dsn)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	switch flag.Arg(0) {
+	case "", "serve":
+		serve()
+	case "cache":
+		cacheCommand(flag.Args()[1:])
+	case "migrate":
+		migrateCommand(flag.Args()[1:])
+	case "apikey":
+		apikeyCommand(flag.Args()[1:])
+	default:
+		log.Fatalf("Unknown command %q (expected serve, cache, migrate or apikey)", flag.Arg(0))
 	}
-	defer db.Close()
+}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+// serve runs the HTTP server until it receives SIGINT or SIGTERM, then shuts
+// down gracefully.
+func serve() {
+	// Log as JSON from here on; fatal errors still go through the log package
+	if err := logging.Setup(os.Stderr); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
 	}
 
-	// Initialize Redis cache
-	redisCache, err := cache.NewCache()
+	// Setup tracing before anything creates spans
+	flushTraces, err := tracing.Setup(context.Background())
 	if err != nil {
-		log.Printf("Warning: Failed to initialize Redis cache: %v (continuing without cache)", err)
-		redisCache = nil
+		log.Fatalf("Invalid tracing configuration: %v", err)
 	}
 
+	// Setup database
+	repoProvider, store := openStorage()
+
+	// Initialize Redis cache. It connects in the background, so the server
+	// starts (without caching) even if Redis is down. Invalid Redis settings
+	// are a deployment mistake, so those fail fast.
+	redisCache, err := cache.NewReconnectingCache()
+	if err != nil {
+		log.Fatalf("Invalid Redis configuration: %v", err)
+	}
+
+	// Background work started by the server, stopped and waited for on shutdown
+	background, stopBackground := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
 	// Create Echo instance
 	e := echo.New()
+	// The server is announced by a log line instead
+	e.HideBanner = true
+	e.HidePort = true
+	// Answer errors with RFC 7807 problem details
+	e.HTTPErrorHandler = api.HTTPErrorHandler
 
-	// Middleware
-	e.Use(middleware.Logger())
+	// Middleware. Recover comes after the observing middlewares, so that a
+	// panic reaches them as the 500 it is answered with.
+	e.Use(tracing.Middleware())
+	e.Use(logging.Middleware())
+	e.Use(metrics.Middleware())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
-	// Health check endpoint
-	e.GET("/health", func(c echo.Context) error {
-		return c.JSON(200, map[string]string{"status": "ok"})
-	})
+	// Keep clients reading their own writes when reads go to replicas
+	if store.replicas != nil {
+		e.Use(api.ReadYourWrites(viper.GetDuration("db.replica_pin")))
+	}
+
+	// Liveness and readiness probes
+	checker := newHealthChecker(store, redisCache)
+	checker.Routes(e)
+
+	// Prometheus metrics
+	registerDBMetrics(store)
+	e.GET("/metrics", metrics.Handler())
+
+	// Create service
+	bookService := books.NewBookService(repoProvider, redisCache)
+
+	// Authenticate API requests with API keys or JWTs
+	authenticator, err := auth.NewAuthenticator(store.APIKeys())
+	if err != nil {
+		log.Fatalf("Invalid auth configuration: %v", err)
+	}
 
 	// API routes
 	v1 := e.Group("/api/v1")
-	api.InitRoutes(v1, db, redisCache)
+	api.InitRoutes(v1, bookService, redisCache, authenticator)
+
+	// Warm the cache once Redis is reachable
+	if viper.GetBool("cache.warm.on_startup") {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			select {
+			case <-redisCache.Ready():
+			case <-background.Done():
+				return
+			}
+			if _, err := bookService.Warm(background, warmOptions()); err != nil && background.Err() == nil {
+				slog.Warn("failed to warm cache", "error", err.Error())
+			}
+		}()
+	}
 
 	// Start server
 	serverPort := viper.GetString("server.port")
-	log.Printf("Starting server on port %s", serverPort)
-	if err := e.Start(":" + serverPort); err != nil {
-		log.Fatal(err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "port", serverPort)
+		if err := e.Start(":" + serverPort); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for an interrupt or for the server to fail
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		slog.Info("shutting down", "signal", sig.String())
+	case err := <-serverErr:
+		slog.Error("server failed", "error", err.Error())
+		exitCode = 1
 	}
+
+	// Fail readiness first, so load balancers stop sending new requests
+	checker.Drain()
+	shutdown(e, stopBackground, &workers, redisCache, store, flushTraces)
+	os.Exit(exitCode)
 }
 
+// shutdown stops the server and releases its resources in order: wait for
+// load balancers to notice the failing health check, drain in-flight
+// requests, stop background work, flush pending cache writes and close Redis,
+// close the database, then flush pending spans. Each step shares the
+// server.shutdown_timeout budget.
+func shutdown(e *echo.Echo, stopBackground context.CancelFunc, workers *sync.WaitGroup, redisCache *cache.Cache, store *storage, flushTraces func(context.Context) error) {
+	if delay := viper.GetDuration("server.shutdown_delay"); delay > 0 {
+		slog.Info("waiting before draining requests", "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	timeout := viper.GetDuration("server.shutdown_timeout")
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		slog.Warn("failed to drain in-flight requests", "error", err.Error())
+	}
+
+	stopBackground()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("background work did not stop before the shutdown timeout")
+	}
+
+	if err := redisCache.Close(ctx); err != nil {
+		slog.Warn("failed to drain Redis cache", "error", err.Error())
+	}
+
+	if err := store.Close(); err != nil {
+		slog.Warn("failed to close database", "error", err.Error())
+	}
+
+	if err := flushTraces(ctx); err != nil {
+		slog.Warn("failed to flush traces", "error", err.Error())
+	}
+
+	slog.Info("shutdown complete")
+}
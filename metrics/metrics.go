@@ -0,0 +1,124 @@
+// Package metrics holds the Prometheus metrics of the service and serves them
+// on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/books/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric of the service. A dedicated registry keeps
+// metrics registered by dependencies out of the output.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "books_http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "books_http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "books_repository_query_duration_seconds",
+		Help:    "Repository call latency by backend, method and outcome (ok or error).",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"backend", "method", "outcome"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "books_cache_requests_total",
+		Help: "Cache lookups by key family and result (hit, miss or error).",
+	}, []string{"family", "result"})
+)
+
+// Cache lookup results.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		queryDuration,
+		cacheRequests,
+	)
+}
+
+// ObserveQuery records the duration of a repository call. failed is true
+// when the call failed for a reason other than a domain error such as "not
+// found".
+func ObserveQuery(backend, method string, d time.Duration, failed bool) {
+	outcome := "ok"
+	if failed {
+		outcome = "error"
+	}
+	queryDuration.WithLabelValues(backend, method, outcome).Observe(d.Seconds())
+}
+
+// CacheLookup counts a cache lookup in a key family.
+func CacheLookup(family, result string) {
+	cacheRequests.WithLabelValues(family, result).Inc()
+}
+
+// RegisterDB exports the connection pool statistics of a database, e.g.
+// open, in-use and idle connections and wait time. name tells databases
+// apart, e.g. primary.
+func RegisterDB(name string, db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware counts requests and measures their latency. Requests are
+// labelled with the route pattern, e.g. /api/v1/books/:id, so that IDs do not
+// create a series each. A panic is counted as a 500 and passed on to the
+// recovering middleware, wherever that is registered.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			defer func() {
+				if r := recover(); r != nil {
+					observe(c, http.StatusInternalServerError, start)
+					panic(r)
+				}
+			}()
+
+			err := next(c)
+			observe(c, logging.ResponseStatus(c, err), start)
+			return err
+		}
+	}
+}
+
+// observe counts a request that started at start and ended with status.
+func observe(c echo.Context, status int, start time.Time) {
+	route := c.Path()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+	httpRequests.WithLabelValues(labels...).Inc()
+	httpDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
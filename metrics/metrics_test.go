@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	. "github.com/smartystreets/goconvey/convey"
+	_ "modernc.org/sqlite"
+)
+
+func Test_Metrics(t *testing.T) {
+	Convey("Metrics", t, func() {
+		e := echo.New()
+		e.Use(Middleware())
+		e.GET("/metrics", Handler())
+		e.GET("/books/:id", func(c echo.Context) error {
+			if c.Param("id") == "0" {
+				return echo.NewHTTPError(http.StatusNotFound, "not found")
+			}
+			return c.String(http.StatusOK, "book")
+		})
+
+		server := httptest.NewServer(e)
+		defer server.Close()
+
+		get := func(path string) string {
+			resp, err := http.Get(server.URL + path)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			return string(body)
+		}
+
+		Convey("Label requests with the route and status", func() {
+			get("/books/1")
+			get("/books/2")
+			get("/books/0")
+
+			scrape := get("/metrics")
+			So(scrape, ShouldContainSubstring, `books_http_requests_total{method="GET",route="/books/:id",status="200"} 2`)
+			So(scrape, ShouldContainSubstring, `books_http_requests_total{method="GET",route="/books/:id",status="404"} 1`)
+			So(scrape, ShouldContainSubstring, `books_http_request_duration_seconds_bucket{method="GET",route="/books/:id",status="200",le="+Inf"} 2`)
+		})
+
+		Convey("Count panicking handlers as 500", func() {
+			// Recover registered before Middleware, so the panic passes
+			// through it, and after it, as in main.go
+			outer := echo.New()
+			outer.Use(middleware.Recover())
+			outer.Use(Middleware())
+			outer.GET("/recover-outside", func(c echo.Context) error { panic("boom") })
+			inner := echo.New()
+			inner.Use(Middleware())
+			inner.Use(middleware.Recover())
+			inner.GET("/recover-inside", func(c echo.Context) error { panic("boom") })
+
+			for _, r := range []struct {
+				e    *echo.Echo
+				path string
+			}{{outer, "/recover-outside"}, {inner, "/recover-inside"}} {
+				w := httptest.NewRecorder()
+				r.e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, r.path, nil))
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			}
+
+			scrape := get("/metrics")
+			So(scrape, ShouldContainSubstring, `books_http_requests_total{method="GET",route="/recover-outside",status="500"} 1`)
+			So(scrape, ShouldContainSubstring, `books_http_requests_total{method="GET",route="/recover-inside",status="500"} 1`)
+		})
+
+		Convey("Expose repository, cache and runtime metrics", func() {
+			ObserveQuery("mysql", "GetByID", 3*time.Millisecond, false)
+			ObserveQuery("mysql", "Update", time.Millisecond, true)
+			CacheLookup("byid", CacheHit)
+			CacheLookup("list", CacheMiss)
+
+			scrape := get("/metrics")
+			So(scrape, ShouldContainSubstring, `books_repository_query_duration_seconds_count{backend="mysql",method="GetByID",outcome="ok"} 1`)
+			So(scrape, ShouldContainSubstring, `books_repository_query_duration_seconds_count{backend="mysql",method="Update",outcome="error"} 1`)
+			So(scrape, ShouldContainSubstring, `books_cache_requests_total{family="byid",result="hit"} 1`)
+			So(scrape, ShouldContainSubstring, `books_cache_requests_total{family="list",result="miss"} 1`)
+			So(scrape, ShouldContainSubstring, "go_goroutines")
+		})
+
+		Convey("Expose the connection pool statistics", func() {
+			db, err := sql.Open("sqlite", ":memory:")
+			So(err, ShouldBeNil)
+			defer db.Close()
+			So(RegisterDB("test", db), ShouldBeNil)
+
+			So(get("/metrics"), ShouldContainSubstring, `go_sql_max_open_connections{db_name="test"}`)
+		})
+	})
+}
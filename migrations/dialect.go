@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// dialect holds the driver specific bookkeeping SQL and locking.
+type dialect struct {
+	createTable   string
+	selectApplied string
+	insert        string
+	delete        string
+	lock          func(ctx context.Context, conn *sqlx.Conn) error
+	unlock        func(ctx context.Context, conn *sqlx.Conn) error
+}
+
+// dialectFor returns the dialect of a database driver.
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "mysql":
+		return mysqlDialect, nil
+	case "postgres":
+		return postgresDialect, nil
+	case "sqlite":
+		return sqliteDialect, nil
+	default:
+		return dialect{}, errors.Errorf("unsupported migration driver %q", driver)
+	}
+}
+
+// mysqlDialect locks with GET_LOCK, which is held by the session, so it must
+// be taken and released on the same connection.
+var mysqlDialect = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT UNSIGNED NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  appliedAt DATETIME(6) NOT NULL,
+  PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+	selectApplied: `SELECT version, name, checksum, appliedAt FROM schema_migrations ORDER BY version`,
+	insert:        `INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (?, ?, ?, ?)`,
+	delete:        `DELETE FROM schema_migrations WHERE version = ?`,
+	lock: func(ctx context.Context, conn *sqlx.Conn) error {
+		var acquired sql.NullInt64
+		err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, int(lockTimeout.Seconds())).Scan(&acquired)
+		if err != nil {
+			return err
+		}
+		if !acquired.Valid || acquired.Int64 != 1 {
+			return errors.Errorf("timed out after %s waiting for lock %s", lockTimeout, lockName)
+		}
+		return nil
+	},
+	unlock: func(ctx context.Context, conn *sqlx.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName)
+		return err
+	},
+}
+
+// postgresDialect locks with a session level advisory lock keyed by a hash of
+// lockName.
+var postgresDialect = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  "appliedAt" TIMESTAMPTZ NOT NULL
+)`,
+	selectApplied: `SELECT version, name, checksum, "appliedAt" FROM schema_migrations ORDER BY version`,
+	insert:        `INSERT INTO schema_migrations (version, name, checksum, "appliedAt") VALUES ($1, $2, $3, $4)`,
+	delete:        `DELETE FROM schema_migrations WHERE version = $1`,
+	lock: func(ctx context.Context, conn *sqlx.Conn) error {
+		ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, lockName)
+		return err
+	},
+	unlock: func(ctx context.Context, conn *sqlx.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, lockName)
+		return err
+	},
+}
+
+// sqliteDialect needs no lock: SQLite databases are local to one process.
+var sqliteDialect = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  appliedAt DATETIME NOT NULL
+)`,
+	selectApplied: `SELECT version, name, checksum, appliedAt FROM schema_migrations ORDER BY version`,
+	insert:        `INSERT INTO schema_migrations (version, name, checksum, appliedAt) VALUES (?, ?, ?, ?)`,
+	delete:        `DELETE FROM schema_migrations WHERE version = ?`,
+	lock:          func(context.Context, *sqlx.Conn) error { return nil },
+	unlock:        func(context.Context, *sqlx.Conn) error { return nil },
+}
@@ -0,0 +1,174 @@
+// Package migrations embeds the database schema migrations and applies them.
+//
+// Each driver has its own directory. A migration is a NNN_name.sql file,
+// applied when migrating up, and an optional NNN_name.down.sql file that
+// reverts it. Applied migrations are recorded in the schema_migrations table
+// together with a checksum of their up script.
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var embedded embed.FS
+
+// Migration is a single schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// fileName matches NNN_name.sql and NNN_name.down.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(.+?)(\.down)?\.sql$`)
+
+// Source returns the embedded migrations for a database driver.
+func Source(driver string) (fs.FS, error) {
+	switch driver {
+	case "mysql", "postgres", "sqlite":
+		return fs.Sub(embedded, driver)
+	default:
+		return nil, errors.Errorf("no migrations for driver %q", driver)
+	}
+}
+
+// Load reads the migrations in the root of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid migration version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, errors.Errorf("migration %d has files with different names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] != "" {
+			m.Down = string(content)
+		} else {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, errors.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Latest returns the highest version embedded for driver, or 0 if there are none.
+func Latest(driver string) (int, error) {
+	source, err := Source(driver)
+	if err != nil {
+		return 0, err
+	}
+	list, err := Load(source)
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, nil
+	}
+	return list[len(list)-1].Version, nil
+}
+
+// splitStatements splits a script into statements on semicolons that are not
+// inside quotes or comments. Drivers such as MySQL only accept one statement
+// per Exec.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	lineComment := false
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case lineComment:
+			if r == '\n' {
+				lineComment = false
+			}
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			lineComment = true
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ';':
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+			continue
+		}
+
+		current.WriteRune(r)
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" && !onlyComments(stmt) {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+// onlyComments reports whether stmt consists of line comments only.
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
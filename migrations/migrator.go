@@ -0,0 +1,337 @@
+package migrations
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ErrChecksumMismatch is returned when an applied migration was edited after
+// it ran. Migrations are immutable; write a new one instead.
+var ErrChecksumMismatch = errors.New("applied migration has been modified")
+
+// lockName identifies the advisory lock held while migrating.
+const lockName = "books_schema_migrations"
+
+// lockTimeout bounds how long a replica waits for another one to finish.
+const lockTimeout = 5 * time.Minute
+
+// Migration states reported by Status.
+const (
+	StatePending  = "pending"
+	StateApplied  = "applied"
+	StateModified = "modified"
+	StateMissing  = "missing"
+)
+
+// Status describes a migration and whether it has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+// record is a row of the schema_migrations table.
+type record struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"appliedAt"`
+}
+
+// Migrator applies and reverts migrations.
+type Migrator struct {
+	db         *sqlx.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migrations in source. driver is one
+// of mysql, postgres or sqlite.
+func NewMigrator(db *sqlx.DB, driver string, source fs.FS) (*Migrator, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := Load(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: list}, nil
+}
+
+// NewEmbeddedMigrator returns a Migrator for the migrations embedded for driver.
+func NewEmbeddedMigrator(db *sqlx.DB, driver string) (*Migrator, error) {
+	source, err := Source(driver)
+	if err != nil {
+		return nil, err
+	}
+	return NewMigrator(db, driver, source)
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest applied migration version, or 0. Unlike the
+// other methods it does not create the schema_migrations table, so it fails
+// on a database that was never migrated.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	return highest(applied), nil
+}
+
+// Status lists every known or applied migration with its state.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Version: migration.Version, Name: migration.Name, State: StatePending}
+		if rec, ok := applied[migration.Version]; ok {
+			appliedAt := rec.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.State = StateApplied
+			if rec.Checksum != migration.Checksum {
+				status.State = StateModified
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Applied migrations whose files no longer exist
+	for version, rec := range applied {
+		if !known[version] {
+			appliedAt := rec.AppliedAt
+			statuses = append(statuses, Status{Version: version, Name: rec.Name, State: StateMissing, AppliedAt: &appliedAt})
+		}
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns the versions applied.
+func (m *Migrator) Up(ctx context.Context) ([]int, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migration and returns its version.
+func (m *Migrator) Down(ctx context.Context) ([]int, error) {
+	var reverted []int
+	err := m.locked(ctx, func(conn *sqlx.Conn, applied map[int]record) error {
+		current := highest(applied)
+		if current == 0 {
+			return nil
+		}
+
+		target := 0
+		for version := range applied {
+			if version < current && version > target {
+				target = version
+			}
+		}
+
+		var err error
+		reverted, err = m.migrate(ctx, conn, applied, target)
+		return err
+	})
+	return reverted, err
+}
+
+// To migrates up or down until target is the highest applied version and
+// returns the versions applied or reverted.
+func (m *Migrator) To(ctx context.Context, target int) ([]int, error) {
+	if target < 0 || (target > 0 && m.find(target) == nil) {
+		return nil, errors.Errorf("unknown migration version %d", target)
+	}
+
+	var changed []int
+	err := m.locked(ctx, func(conn *sqlx.Conn, applied map[int]record) error {
+		var err error
+		changed, err = m.migrate(ctx, conn, applied, target)
+		return err
+	})
+	return changed, err
+}
+
+// migrate applies or reverts migrations until target is the highest applied
+// version. It must be called with the lock held.
+func (m *Migrator) migrate(ctx context.Context, conn *sqlx.Conn, applied map[int]record, target int) ([]int, error) {
+	var changed []int
+
+	// Revert, newest first
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return changed, errors.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+
+		slog.InfoContext(ctx, "reverting migration", "version", migration.Version, "name", migration.Name)
+		err := m.exec(ctx, conn, migration.Down, m.dialect.delete, migration.Version)
+		if err != nil {
+			return changed, errors.Wrapf(err, "revert migration %d_%s", migration.Version, migration.Name)
+		}
+		changed = append(changed, migration.Version)
+	}
+
+	// Apply, oldest first
+	for _, migration := range m.migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		slog.InfoContext(ctx, "applying migration", "version", migration.Version, "name", migration.Name)
+		err := m.exec(ctx, conn, migration.Up, m.dialect.insert, migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+		if err != nil {
+			return changed, errors.Wrapf(err, "apply migration %d_%s", migration.Version, migration.Name)
+		}
+		changed = append(changed, migration.Version)
+	}
+
+	return changed, nil
+}
+
+// exec runs script followed by the bookkeeping statement in a transaction.
+// MySQL commits DDL implicitly, so there a failing script may leave partial
+// changes behind; PostgreSQL and SQLite roll them back.
+func (m *Migrator) exec(ctx context.Context, conn *sqlx.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after Commit
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// locked runs fn on a dedicated connection holding the advisory lock, after
+// verifying the checksums of the applied migrations.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int]record) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn); err != nil {
+		return errors.Wrap(err, "acquire migration lock")
+	}
+	defer func() {
+		// Release even if ctx was cancelled
+		if err := m.dialect.unlock(context.Background(), conn); err != nil {
+			slog.WarnContext(ctx, "failed to release migration lock", "error", err.Error())
+		}
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if rec, ok := applied[migration.Version]; ok && rec.Checksum != migration.Checksum {
+			return errors.Wrapf(ErrChecksumMismatch, "%d_%s", migration.Version, migration.Name)
+		}
+	}
+
+	return fn(conn, applied)
+}
+
+// ensureTable creates the schema_migrations table if needed.
+func (m *Migrator) ensureTable(ctx context.Context, conn *sqlx.Conn) error {
+	_, err := conn.ExecContext(ctx, m.dialect.createTable)
+	return err
+}
+
+// applied returns the applied migrations by version.
+func (m *Migrator) applied(ctx context.Context, conn *sqlx.Conn) (map[int]record, error) {
+	var records []record
+	err := conn.SelectContext(ctx, &records, m.dialect.selectApplied)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// find returns the migration with the given version, or nil.
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// highest returns the highest applied version, or 0.
+func highest(applied map[int]record) int {
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current
+}
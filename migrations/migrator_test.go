@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	_ "modernc.org/sqlite"
+)
+
+func Test_Migrator(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Migrator", t, func() {
+		db, err := sqlx.Open("sqlite", ":memory:")
+		So(err, ShouldBeNil)
+		db.SetMaxOpenConns(1)
+		Reset(func() { db.Close() })
+
+		source := fstest.MapFS{
+			"001_create_a.sql":      {Data: []byte("CREATE TABLE a (id INTEGER);\nCREATE TABLE a2 (id INTEGER);")},
+			"001_create_a.down.sql": {Data: []byte("DROP TABLE a2;\nDROP TABLE a;")},
+			"002_create_b.sql":      {Data: []byte("-- Semicolons in strings are kept\nCREATE TABLE b (name TEXT DEFAULT 'x;y');")},
+			"002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+			"README.md":             {Data: []byte("ignored")},
+		}
+
+		migrator, err := NewMigrator(db, "sqlite", source)
+		So(err, ShouldBeNil)
+		So(migrator.Latest(), ShouldEqual, 2)
+
+		Convey("Up applies every pending migration once", func() {
+			applied, err := migrator.Up(ctx)
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, []int{1, 2})
+			So(tables(db), ShouldResemble, []string{"a", "a2", "b", "schema_migrations"})
+
+			applied, err = migrator.Up(ctx)
+			So(err, ShouldBeNil)
+			So(applied, ShouldBeEmpty)
+		})
+
+		Convey("Down reverts the latest migration", func() {
+			_, err := migrator.Up(ctx)
+			So(err, ShouldBeNil)
+
+			reverted, err := migrator.Down(ctx)
+			So(err, ShouldBeNil)
+			So(reverted, ShouldResemble, []int{2})
+
+			version, err := migrator.Version(ctx)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 1)
+		})
+
+		Convey("To migrates in both directions", func() {
+			changed, err := migrator.To(ctx, 1)
+			So(err, ShouldBeNil)
+			So(changed, ShouldResemble, []int{1})
+
+			changed, err = migrator.To(ctx, 0)
+			So(err, ShouldBeNil)
+			So(changed, ShouldResemble, []int{1})
+			So(tables(db), ShouldResemble, []string{"schema_migrations"})
+
+			_, err = migrator.To(ctx, 3)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Status reports pending and applied migrations", func() {
+			_, err := migrator.To(ctx, 1)
+			So(err, ShouldBeNil)
+
+			statuses, err := migrator.Status(ctx)
+			So(err, ShouldBeNil)
+			So(statuses, ShouldHaveLength, 2)
+			So(statuses[0].State, ShouldEqual, StateApplied)
+			So(statuses[0].AppliedAt, ShouldNotBeNil)
+			So(statuses[1].State, ShouldEqual, StatePending)
+		})
+
+		Convey("Refuse to migrate after an applied migration was edited", func() {
+			_, err := migrator.Up(ctx)
+			So(err, ShouldBeNil)
+
+			source["001_create_a.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id BIGINT);")}
+			edited, err := NewMigrator(db, "sqlite", source)
+			So(err, ShouldBeNil)
+
+			_, err = edited.Down(ctx)
+			So(errors.Is(err, ErrChecksumMismatch), ShouldBeTrue)
+
+			statuses, err := edited.Status(ctx)
+			So(err, ShouldBeNil)
+			So(statuses[0].State, ShouldEqual, StateModified)
+		})
+	})
+}
+
+// tables returns the names of the tables in the database.
+func tables(db *sqlx.DB) []string {
+	var names []string
+	err := db.Select(&names, "SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+	So(err, ShouldBeNil)
+	return names
+}
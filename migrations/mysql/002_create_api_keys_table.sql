@@ -0,0 +1,13 @@
+-- Create api_keys table
+-- Only a SHA-256 hash of each key is stored; prefix identifies the key.
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INT(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(32) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  role VARCHAR(32) NOT NULL,
+  createdAt DATETIME(6) NOT NULL,
+  revokedAt DATETIME(6) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `UQ_api_keys_prefix` (`prefix`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,20 @@
+-- Create books table
+-- Translated from ../mysql/001_create_books_table.sql. Column names are quoted to
+-- keep their camelCase spelling. The unique index only covers books that are
+-- not soft deleted, so a deleted book can be created again.
+CREATE TABLE IF NOT EXISTS books (
+  id BIGSERIAL PRIMARY KEY,
+  title VARCHAR(255) NOT NULL,
+  author VARCHAR(255) NOT NULL,
+  isbn VARCHAR(13),
+  description TEXT,
+  "publishedAt" TIMESTAMP(6) NOT NULL,
+  "createdAt" TIMESTAMP(6) NOT NULL,
+  "updatedAt" TIMESTAMP(6) NOT NULL,
+  "deletedAt" TIMESTAMP(6) NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "UQ_books_title_author_isbn" ON books (title, author, isbn) WHERE "deletedAt" IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_deletedAt" ON books ("deletedAt");
+CREATE INDEX IF NOT EXISTS idx_author ON books (author);
+CREATE INDEX IF NOT EXISTS idx_title ON books (title);
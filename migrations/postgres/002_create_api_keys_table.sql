@@ -0,0 +1,13 @@
+-- Create api_keys table
+-- Translated from ../mysql/002_create_api_keys_table.sql. Column names are
+-- quoted to keep their camelCase spelling.
+CREATE TABLE IF NOT EXISTS api_keys (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(32) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  role VARCHAR(32) NOT NULL,
+  "createdAt" TIMESTAMP(6) NOT NULL,
+  "revokedAt" TIMESTAMP(6) NULL,
+  CONSTRAINT "UQ_api_keys_prefix" UNIQUE (prefix)
+);
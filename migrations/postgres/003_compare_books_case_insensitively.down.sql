@@ -0,0 +1,5 @@
+-- Compare titles, authors and ISBNs case-sensitively again
+DROP INDEX IF EXISTS "UQ_books_title_author_isbn";
+CREATE UNIQUE INDEX "UQ_books_title_author_isbn" ON books (title, author, isbn) WHERE "deletedAt" IS NULL;
+DROP INDEX IF EXISTS idx_author;
+CREATE INDEX idx_author ON books (author);
@@ -0,0 +1,7 @@
+-- Compare titles, authors and ISBNs case-insensitively
+-- MySQL's default collation and SQLite's NOCASE ignore case; lower() makes the
+-- unique index and the author lookups of PostgreSQL do the same.
+DROP INDEX IF EXISTS "UQ_books_title_author_isbn";
+CREATE UNIQUE INDEX "UQ_books_title_author_isbn" ON books (lower(title), lower(author), lower(isbn)) WHERE "deletedAt" IS NULL;
+DROP INDEX IF EXISTS idx_author;
+CREATE INDEX idx_author ON books (lower(author));
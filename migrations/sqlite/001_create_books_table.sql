@@ -0,0 +1,19 @@
+-- Create books table
+-- Translated from ../mysql/001_create_books_table.sql. NOCASE mirrors the
+-- case-insensitive comparisons of MySQL's default collation.
+CREATE TABLE IF NOT EXISTS books (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  title VARCHAR(255) NOT NULL COLLATE NOCASE,
+  author VARCHAR(255) NOT NULL COLLATE NOCASE,
+  isbn VARCHAR(13) COLLATE NOCASE,
+  description TEXT,
+  publishedAt DATETIME NOT NULL,
+  createdAt DATETIME NOT NULL,
+  updatedAt DATETIME NOT NULL,
+  deletedAt DATETIME NULL,
+  CONSTRAINT UQ_books_title_author_isbn UNIQUE (title, author, isbn)
+);
+
+CREATE INDEX IF NOT EXISTS idx_deletedAt ON books (deletedAt);
+CREATE INDEX IF NOT EXISTS idx_author ON books (author);
+CREATE INDEX IF NOT EXISTS idx_title ON books (title);
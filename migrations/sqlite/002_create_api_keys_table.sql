@@ -0,0 +1,12 @@
+-- Create api_keys table
+-- Translated from ../mysql/002_create_api_keys_table.sql.
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(32) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  role VARCHAR(32) NOT NULL,
+  createdAt DATETIME NOT NULL,
+  revokedAt DATETIME NULL,
+  CONSTRAINT UQ_api_keys_prefix UNIQUE (prefix)
+);
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/books/auth"
+	"github.com/books/books"
+	"github.com/books/books/mysql"
+	"github.com/books/books/postgres"
+	"github.com/books/books/sqlite"
+	"github.com/books/metrics"
+	"github.com/books/migrations"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+)
+
+// storage holds the database connections opened by openStorage.
+type storage struct {
+	driver   string
+	db       *sqlx.DB
+	replicas *mysql.Replicas
+}
+
+// Close closes the primary and replica connections.
+func (s *storage) Close() error {
+	if s.replicas != nil {
+		if err := s.replicas.Close(); err != nil {
+			slog.Warn("failed to close read replicas", "error", err.Error())
+		}
+	}
+	return s.db.Close()
+}
+
+// APIKeys returns the API key repository of the primary database.
+func (s *storage) APIKeys() auth.KeyRepository {
+	switch s.driver {
+	case "sqlite":
+		return sqlite.NewAPIKeyRepository(s.db)
+	case "postgres":
+		return postgres.NewAPIKeyRepository(s.db)
+	default:
+		return mysql.NewAPIKeyRepository(s.db)
+	}
+}
+
+// openStorage connects to the database selected by db.driver and returns the
+// repository provider together with the connections to close on exit.
+// Pending migrations are applied first if db.migrate_on_start is set.
+func openStorage() (books.RepositoryProvider, *storage) {
+	driver, db := openDB()
+	store := &storage{driver: driver, db: db}
+
+	if viper.GetBool("db.migrate_on_start") {
+		migrator := newMigrator(driver, db)
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	switch driver {
+	case "sqlite":
+		return sqlite.NewRepositoryProvider(db), store
+	case "postgres":
+		return postgres.NewRepositoryProvider(db), store
+	default:
+		if addrs := replicaAddrs(); len(addrs) > 0 {
+			store.replicas = openMySQLReplicas(addrs)
+			return mysql.NewReplicatedRepositoryProvider(db, store.replicas), store
+		}
+		return mysql.NewRepositoryProvider(db), store
+	}
+}
+
+// replicaAddrs returns the host:port addresses in db.replicas. The
+// BOOKS_DB_REPLICAS environment variable is a comma separated list.
+func replicaAddrs() []string {
+	var addrs []string
+	for _, addr := range viper.GetStringSlice("db.replicas") {
+		for _, a := range strings.Split(addr, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	return addrs
+}
+
+// openMySQLReplicas connects to the read replicas at addrs with the options
+// of the primary.
+func openMySQLReplicas(addrs []string) *mysql.Replicas {
+	replicas, err := mysql.OpenReplicas(context.Background(), mysql.LoadConfig("db"), addrs, viper.GetDuration("db.replica_check_interval"))
+	if err != nil {
+		log.Fatalf("Failed to open read replicas: %v", err)
+	}
+	slog.Info("routing reads to read replicas", "healthy", replicas.Healthy(), "replicas", len(addrs))
+	return replicas
+}
+
+// openDB connects to the database selected by db.driver and returns the
+// normalized driver name with the connection.
+func openDB() (string, *sqlx.DB) {
+	switch driver := viper.GetString("db.driver"); driver {
+	case "", "mysql":
+		return "mysql", openMySQL()
+	case "sqlite":
+		path := viper.GetString("db.path")
+		if path == "" {
+			path = "books.db"
+		}
+		db, err := sqlite.Open(context.Background(), path)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database %s: %v", path, err)
+		}
+		return driver, db
+	case "postgres":
+		cfg := postgres.Config{
+			Host:     viper.GetString("db.host"),
+			Port:     viper.GetInt("db.port"),
+			User:     viper.GetString("db.user"),
+			Password: viper.GetString("db.password"),
+			Database: viper.GetString("db.database"),
+			SSLMode:  viper.GetString("db.sslmode"),
+		}
+		db := connectWithRetry("PostgreSQL", func(ctx context.Context) (*sqlx.DB, error) {
+			return postgres.Open(ctx, cfg.DSN())
+		})
+		return driver, db
+	default:
+		log.Fatalf("Unknown db.driver %q (expected mysql, postgres or sqlite)", driver)
+		return "", nil
+	}
+}
+
+// newMigrator returns a migrator for the embedded migrations of driver.
+func newMigrator(driver string, db *sqlx.DB) *migrations.Migrator {
+	migrator, err := migrations.NewEmbeddedMigrator(db, driver)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator
+}
+
+// openMySQL connects to the MySQL database configured in the db section.
+func openMySQL() *sqlx.DB {
+	cfg := mysql.LoadConfig("db")
+
+	// Invalid settings will not fix themselves, so check them before retrying
+	if _, err := cfg.DSN(); err != nil {
+		log.Fatalf("Invalid database configuration: %v", err)
+	}
+
+	return connectWithRetry("MySQL", func(ctx context.Context) (*sqlx.DB, error) {
+		return mysql.Open(ctx, cfg)
+	})
+}
+
+// connectWithRetry calls connect until it succeeds, backing off exponentially
+// between attempts, so that the service can start before the database
+// accepts connections. It exits once db.connect.max_wait has passed.
+func connectWithRetry(name string, connect func(ctx context.Context) (*sqlx.DB, error)) *sqlx.DB {
+	minBackoff := viper.GetDuration("db.connect.min_backoff")
+	if minBackoff <= 0 {
+		minBackoff = 500 * time.Millisecond
+	}
+	maxBackoff := viper.GetDuration("db.connect.max_backoff")
+	if maxBackoff < minBackoff {
+		maxBackoff = 10 * time.Second
+	}
+	maxWait := viper.GetDuration("db.connect.max_wait")
+	if maxWait <= 0 {
+		maxWait = time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+
+	backoff := minBackoff
+	for {
+		db, err := connect(ctx)
+		if err == nil {
+			return db
+		}
+		if ctx.Err() != nil {
+			log.Fatalf("Failed to connect to %s within %s: %v", name, maxWait, err)
+		}
+
+		slog.Warn("database unavailable, retrying", "database", name, "backoff", backoff.String(), "error", err.Error())
+		select {
+		case <-ctx.Done():
+			log.Fatalf("Failed to connect to %s within %s: %v", name, maxWait, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// registerDBMetrics exports the connection pool statistics of the primary and
+// replica databases.
+func registerDBMetrics(store *storage) {
+	if store.db == nil {
+		return
+	}
+	if err := metrics.RegisterDB("primary", store.db.DB); err != nil {
+		slog.Warn("failed to register database metrics", "error", err.Error())
+	}
+	if store.replicas == nil {
+		return
+	}
+	for name, db := range store.replicas.DBs() {
+		if err := metrics.RegisterDB("replica "+name, db.DB); err != nil {
+			slog.Warn("failed to register read replica metrics", "replica", name, "error", err.Error())
+		}
+	}
+}
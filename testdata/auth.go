@@ -0,0 +1,78 @@
+package testdata
+
+import (
+	"context"
+
+	"github.com/books/auth"
+	"github.com/books/books/memory"
+	"github.com/books/books/mysql"
+	"github.com/books/books/sqlite"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeys returns the API key repository of the test database
+func (s *Suite) APIKeys() auth.KeyRepository {
+	switch {
+	case s.memory != nil:
+		if s.keys == nil {
+			s.keys = memory.NewAPIKeyRepository()
+		}
+		return s.keys
+	case s.db != nil && s.driver == "sqlite":
+		return sqlite.NewAPIKeyRepository(s.db)
+	case s.db != nil:
+		return mysql.NewAPIKeyRepository(s.db)
+	default:
+		s.t.Fatal("Database not initialized. Call WithDB() first.")
+		return nil
+	}
+}
+
+// CreateAPIKey stores a new API key with the given role and returns it
+func (s *Suite) CreateAPIKey(name string, role auth.Role) string {
+	secret, key, err := auth.GenerateKey(name, role)
+	if err != nil {
+		s.t.Fatalf("Failed to generate API key: %v", err)
+	}
+	if _, err := s.APIKeys().Create(context.Background(), key); err != nil {
+		s.t.Fatalf("Failed to insert API key: %v", err)
+	}
+	return secret
+}
+
+// ClearAPIKeys clears all API keys from the database
+func (s *Suite) ClearAPIKeys() {
+	if s.memory != nil {
+		s.keys = nil
+		return
+	}
+	if s.db == nil {
+		return
+	}
+
+	if _, err := s.db.Exec("DELETE FROM api_keys"); err != nil {
+		s.t.Fatalf("Failed to clear api_keys table: %v", err)
+	}
+
+	// Reset auto increment
+	resetAutoIncrement := "ALTER TABLE api_keys AUTO_INCREMENT = 1"
+	if s.driver == "sqlite" {
+		resetAutoIncrement = "DELETE FROM sqlite_sequence WHERE name = 'api_keys'"
+	}
+	if _, err := s.db.Exec(resetAutoIncrement); err != nil {
+		// Ignore error if table doesn't exist or doesn't have auto increment
+		s.t.Logf("Warning: Failed to reset auto increment: %v", err)
+	}
+}
+
+// AuthenticateAs makes every request to e authenticated with role, for tests
+// of the routes rather than of authentication
+func (s *Suite) AuthenticateAs(e *echo.Echo, role auth.Role) {
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.Principal{Subject: "test", Role: role, Method: auth.MethodAPIKey}
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+}
@@ -11,6 +11,9 @@ import (
 
 // InsertBook inserts a book into the database
 func (s *Suite) InsertBook(book books.Book) *books.Book {
+	if s.memory != nil {
+		return s.insertMemoryBook(book)
+	}
 	if s.db == nil {
 		s.t.Fatal("Database not initialized. Call WithDB() first.")
 	}
@@ -42,6 +45,16 @@ func (s *Suite) InsertBook(book books.Book) *books.Book {
 
 // GetBook retrieves a book by ID from the database
 func (s *Suite) GetBook(id int64) *books.Book {
+	if s.memory != nil {
+		book, err := s.memory.Book().GetByID(context.Background(), id)
+		if errors.Is(err, books.ErrBookNotFound) {
+			return nil
+		}
+		if err != nil {
+			s.t.Fatalf("Failed to get book: %v", err)
+		}
+		return book
+	}
 	if s.db == nil {
 		s.t.Fatal("Database not initialized. Call WithDB() first.")
 	}
@@ -60,6 +73,11 @@ func (s *Suite) GetBook(id int64) *books.Book {
 
 // ClearBooks clears all books from the database and clears related cache
 func (s *Suite) ClearBooks() {
+	if s.memory != nil {
+		s.memory.Reset()
+		s.flushCache()
+		return
+	}
 	if s.db == nil {
 		return
 	}
@@ -69,15 +87,48 @@ func (s *Suite) ClearBooks() {
 	}
 
 	// Reset auto increment
-	if _, err := s.db.Exec("ALTER TABLE books AUTO_INCREMENT = 1"); err != nil {
+	resetAutoIncrement := "ALTER TABLE books AUTO_INCREMENT = 1"
+	if s.driver == "sqlite" {
+		resetAutoIncrement = "DELETE FROM sqlite_sequence WHERE name = 'books'"
+	}
+	if _, err := s.db.Exec(resetAutoIncrement); err != nil {
 		// Ignore error if table doesn't exist or doesn't have auto increment
 		s.t.Logf("Warning: Failed to reset auto increment: %v", err)
 	}
 
-	// Flush Redis database
+	s.flushCache()
+}
+
+// flushCache flushes the Redis database, if a cache is configured
+func (s *Suite) flushCache() {
 	if s.cache != nil {
 		if err := s.cache.FlushDB(context.Background()); err != nil {
 			s.t.Logf("Warning: Failed to flush Redis database: %v", err)
 		}
 	}
 }
+
+// insertMemoryBook inserts a book into the in-memory repository
+func (s *Suite) insertMemoryBook(book books.Book) *books.Book {
+	now := time.Now().UTC()
+	if book.CreatedAt.IsZero() {
+		book.CreatedAt = now
+	}
+	if book.UpdatedAt.IsZero() {
+		book.UpdatedAt = now
+	}
+
+	created, err := s.memory.Book().Create(context.Background(), book)
+	if err != nil {
+		s.t.Fatalf("Failed to insert book: %v", err)
+	}
+
+	if book.DeletedAt != nil {
+		if err := s.memory.Book().Delete(context.Background(), created.ID); err != nil {
+			s.t.Fatalf("Failed to delete book: %v", err)
+		}
+		created.DeletedAt = book.DeletedAt
+	}
+
+	return created
+}
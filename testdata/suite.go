@@ -1,14 +1,15 @@
 package testdata
 
 import (
-	"fmt"
+	"context"
 	"testing"
 
 	"github.com/books/books"
 	"github.com/books/books/cache"
+	"github.com/books/books/memory"
 	"github.com/books/books/mysql"
+	"github.com/books/books/sqlite"
 	"github.com/books/config"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/viper"
@@ -18,9 +19,12 @@ import (
 type Suite struct {
 	t            *testing.T
 	db           *sqlx.DB
+	memory       *memory.RepositoryProvider
+	keys         *memory.APIKeyRepository
+	driver       string
 	cache        *cache.Cache
 	echo         *echo.Echo
-	repoProvider *mysql.RepositoryProvider
+	repoProvider books.RepositoryProvider
 	service      *books.BookService
 }
 
@@ -35,44 +39,46 @@ func NewSuite(t *testing.T) *Suite {
 	}
 }
 
-// WithDB initialises the test database connection
+// WithDB initialises the test database connection.
+// Setting test.db.driver to "memory" or "sqlite" uses the in-memory
+// repository or an in-memory SQLite database instead, so the tests can run
+// without MySQL.
 func (s *Suite) WithDB() *Suite {
+	s.driver = viper.GetString("test.db.driver")
+	switch s.driver {
+	case "memory":
+		s.memory = memory.NewRepositoryProvider()
+		return s
+	case "sqlite":
+		db, err := sqlite.Open(context.Background(), ":memory:")
+		if err != nil {
+			s.t.Fatalf("Failed to open SQLite test database: %v", err)
+		}
+		s.db = db
+		return s
+	}
+
 	// Get config values with defaults
-	host := viper.GetString("test.db.host")
-	if host == "" {
-		host = "127.0.0.1"
+	cfg := mysql.LoadConfig("test.db")
+	if cfg.Host == "" && cfg.Socket == "" {
+		cfg.Host = "127.0.0.1"
 	}
-	
-	user := viper.GetString("test.db.user")
-	if user == "" {
-		user = "root"
+	if cfg.User == "" {
+		cfg.User = "root"
 	}
-	
-	password := viper.GetString("test.db.password")
-	if password == "" {
-		password = "mysecretpassword"
+	if cfg.Password == "" {
+		cfg.Password = "mysecretpassword"
 	}
-	
-	databaseName := viper.GetString("test.db.database")
-	if databaseName == "" {
-		databaseName = "mysql"
+	if cfg.Database == "" {
+		cfg.Database = "mysql"
 	}
-	
-	port := viper.GetInt64("test.db.port")
-	if port == 0 {
-		port = 3306
+	if cfg.Port == 0 {
+		cfg.Port = 3306
 	}
-	
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", user, password, host, port, databaseName)
 
-	db, err := sqlx.Connect("mysql", dsn)
+	db, err := mysql.Open(context.Background(), cfg)
 	if err != nil {
-		s.t.Fatalf("Failed to connect to test database: %v (DSN: %s)", err, dsn)
-	}
-
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		s.t.Fatalf("Failed to ping test database: %v", err)
+		s.t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
 	s.db = db
@@ -91,6 +97,29 @@ func (s *Suite) WithCache() *Suite {
 	return s
 }
 
+// Cache returns the Redis cache, or nil when WithCache could not connect
+func (s *Suite) Cache() *cache.Cache {
+	return s.cache
+}
+
+// UnavailableCache returns a cache that never connects, so that every call
+// fails with cache.ErrUnavailable. It is closed when the test ends.
+func (s *Suite) UnavailableCache() *cache.Cache {
+	dsn := viper.Get("redis.dsn")
+	addrs := viper.Get("redis.addrs")
+	viper.Set("redis.dsn", "127.0.0.1:1")
+	viper.Set("redis.addrs", []string{})
+	defer viper.Set("redis.dsn", dsn)
+	defer viper.Set("redis.addrs", addrs)
+
+	c, err := cache.NewReconnectingCache()
+	if err != nil {
+		s.t.Fatalf("Failed to create cache: %v", err)
+	}
+	s.t.Cleanup(func() { _ = c.Close(context.Background()) })
+	return c
+}
+
 // Close closes all connections
 func (s *Suite) Close() {
 	if s.db != nil {
@@ -98,14 +127,33 @@ func (s *Suite) Close() {
 	}
 }
 
+// Driver returns the test database driver: mysql, sqlite or memory
+func (s *Suite) Driver() string {
+	if s.driver == "" {
+		return "mysql"
+	}
+	return s.driver
+}
+
+// DB returns the test database connection, or nil when running in memory
+func (s *Suite) DB() *sqlx.DB {
+	return s.db
+}
+
 // SetupAPI initializes the repository provider, service, and echo instance
 // Returns the echo instance, repository provider, and service for controller setup
-func (s *Suite) SetupAPI() (*echo.Echo, *mysql.RepositoryProvider, *books.BookService) {
-	if s.db == nil {
+func (s *Suite) SetupAPI() (*echo.Echo, books.RepositoryProvider, *books.BookService) {
+	switch {
+	case s.memory != nil:
+		s.repoProvider = s.memory
+	case s.db != nil && s.driver == "sqlite":
+		s.repoProvider = sqlite.NewRepositoryProvider(s.db)
+	case s.db != nil:
+		s.repoProvider = mysql.NewRepositoryProvider(s.db)
+	default:
 		s.t.Fatal("Database not initialized. Call WithDB() first.")
 	}
 
-	s.repoProvider = mysql.NewRepositoryProvider(s.db)
 	s.service = books.NewBookService(s.repoProvider, s.cache)
 	s.echo = echo.New()
 
@@ -0,0 +1,174 @@
+// Package tracing sets up OpenTelemetry tracing and traces HTTP requests.
+package tracing
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/books/logging"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters selected by tracing.exporter.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// instrumentation is the name of the tracer used for HTTP requests.
+const instrumentation = "github.com/books/tracing"
+
+// Setup installs the W3C trace context propagator and, unless tracing.exporter
+// is none, a tracer provider exporting spans to the configured exporter. The
+// returned function flushes pending spans and stops the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	// Propagate traceparent even when spans are not exported, so that the
+	// trace of a caller is not broken by this service
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx, viper.GetString("tracing.exporter"))
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	serviceName := viper.GetString("tracing.service_name")
+	if serviceName == "" {
+		serviceName = "books"
+	}
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "tracing resource")
+	}
+
+	ratio := 1.0
+	if viper.IsSet("tracing.sample_ratio") {
+		ratio = viper.GetFloat64("tracing.sample_ratio")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's sampling decision, so traces are not cut short
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		return errors.WithStack(provider.Shutdown(ctx))
+	}, nil
+}
+
+// newExporter returns the span exporter named by name, or nil for none.
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		// The endpoint also honours OTEL_EXPORTER_OTLP_ENDPOINT and friends
+		var opts []otlptracehttp.Option
+		if endpoint := viper.GetString("tracing.otlp.endpoint"); endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		if viper.GetBool("tracing.otlp.insecure") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "OTLP exporter")
+		}
+		slog.Info("exporting traces over OTLP")
+		return exporter, nil
+	case ExporterStdout:
+		return newWriterExporter(os.Stdout)
+	case ExporterFile:
+		path := viper.GetString("tracing.file")
+		if path == "" {
+			return nil, errors.New("tracing.file is required by the file exporter")
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errors.Wrap(err, "open trace file")
+		}
+		slog.Info("writing traces to file", "path", path)
+		return newWriterExporter(f)
+	default:
+		return nil, errors.Errorf("unknown tracing exporter %q", name)
+	}
+}
+
+// newWriterExporter returns an exporter writing one JSON span per line to w.
+func newWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	return exporter, errors.WithStack(err)
+}
+
+// Middleware starts a server span for every request, continuing the trace of
+// the caller's traceparent header. Spans are named after the route pattern,
+// e.g. GET /api/v1/books/:id.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			name := req.Method
+			if route != "" {
+				name += " " + route
+			}
+
+			ctx, span := otel.Tracer(instrumentation).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+					semconv.UserAgentOriginal(req.UserAgent()),
+					semconv.ClientAddress(c.RealIP()),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+			err := next(c)
+			status := logging.ResponseStatus(c, err)
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			// Client errors are not failures of the server
+			if status >= http.StatusInternalServerError {
+				if err != nil {
+					span.RecordError(err)
+				}
+				span.SetStatus(codes.Error, strconv.Itoa(status))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// traceparent is a sampled W3C trace context sent by a caller.
+const (
+	traceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+)
+
+func Test_Tracing(t *testing.T) {
+	Convey("Tracing", t, func() {
+		e := echo.New()
+		e.Use(Middleware())
+		e.GET("/books/:id", func(c echo.Context) error {
+			switch c.Param("id") {
+			case "0":
+				return echo.NewHTTPError(http.StatusNotFound, "book not found")
+			case "-1":
+				return echo.NewHTTPError(http.StatusInternalServerError, "database is down")
+			}
+			return c.String(http.StatusOK, "book")
+		})
+
+		get := func(path string) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("traceparent", traceparent)
+			e.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		Convey("With an in-memory exporter", func() {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			otel.SetTracerProvider(provider)
+			_, err := Setup(context.Background())
+			So(err, ShouldBeNil)
+
+			Convey("Continue the caller's trace and name spans after the route", func() {
+				get("/books/1")
+
+				spans := exporter.GetSpans()
+				So(spans, ShouldHaveLength, 1)
+				So(spans[0].Name, ShouldEqual, "GET /books/:id")
+				So(spans[0].SpanContext.TraceID().String(), ShouldEqual, traceID)
+				So(spans[0].Parent.IsRemote(), ShouldBeTrue)
+				So(spans[0].Attributes, ShouldContain, attribute.Int("http.response.status_code", 200))
+			})
+
+			Convey("Only mark server errors as failed", func() {
+				get("/books/0")
+				get("/books/-1")
+
+				spans := exporter.GetSpans()
+				So(spans, ShouldHaveLength, 2)
+				So(spans[0].Status.Code, ShouldEqual, codes.Unset)
+				So(spans[0].Attributes, ShouldContain, attribute.Int("http.response.status_code", 404))
+				So(spans[1].Status.Code, ShouldEqual, codes.Error)
+			})
+		})
+
+		Convey("Write spans to a file", func() {
+			path := filepath.Join(t.TempDir(), "traces.json")
+			viper.Set("tracing.exporter", ExporterFile)
+			viper.Set("tracing.file", path)
+			defer viper.Set("tracing.exporter", "")
+
+			flush, err := Setup(context.Background())
+			So(err, ShouldBeNil)
+			get("/books/1")
+			So(flush(context.Background()), ShouldBeNil)
+
+			data, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, traceID)
+			So(string(data), ShouldContainSubstring, `"Name":"GET /books/:id"`)
+		})
+
+		Convey("Reject an unknown exporter", func() {
+			viper.Set("tracing.exporter", "zipkin")
+			defer viper.Set("tracing.exporter", "")
+
+			_, err := Setup(context.Background())
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
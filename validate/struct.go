@@ -0,0 +1,155 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule is a rule usable in struct tags. param is the text after "=" in the
+// tag, e.g. 255 in max=255, or "" when there is none. A rule adds its errors
+// to v and should accept empty values unless it checks for presence.
+type Rule func(v *Validator, field, value, param string)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{
+		"required": func(v *Validator, field, value, _ string) {
+			v.Required(field, value)
+		},
+		"min": func(v *Validator, field, value, param string) {
+			v.MinLen(field, value, atoi(param))
+		},
+		"max": func(v *Validator, field, value, param string) {
+			v.MaxLen(field, value, atoi(param))
+		},
+		"pattern": func(v *Validator, field, value, param string) {
+			v.Pattern(field, value, compile(param))
+		},
+		"oneof": func(v *Validator, field, value, param string) {
+			v.OneOf(field, value, strings.Fields(param)...)
+		},
+		"isbn": func(v *Validator, field, value, _ string) {
+			v.ISBN(field, value)
+		},
+		"url": func(v *Validator, field, value, _ string) {
+			v.URL(field, value)
+		},
+		"date": func(v *Validator, field, value, param string) {
+			if param == "" {
+				param = DateLayout
+			}
+			v.Date(field, value, param)
+		},
+		"datebetween": func(v *Validator, field, value, param string) {
+			// Values that do not parse are reported by the date rule
+			t, err := time.Parse(DateLayout, value)
+			if err != nil {
+				return
+			}
+			bounds := strings.Fields(param)
+			if len(bounds) != 2 {
+				panic(fmt.Sprintf("validate: datebetween needs two dates, got %q", param))
+			}
+			v.DateBetween(field, t, parseDate(bounds[0]), parseDate(bounds[1]))
+		},
+	}
+
+	// patterns caches the regular expressions of pattern rules.
+	patterns sync.Map
+)
+
+// Register adds a rule usable in struct tags as name, replacing any rule with
+// the same name.
+func Register(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+// Struct checks the string fields of the struct s, or of the struct s points
+// to, against the rules in their validate tags, e.g.
+//
+//	Title string `json:"title" validate:"required,max=255"`
+//
+// Rules are separated by commas, so parameters cannot contain commas. Fields
+// are named after their json tag. Unknown rules and invalid parameters are
+// programming errors and panic.
+func (v *Validator) Struct(s interface{}) {
+	val := reflect.Indirect(reflect.ValueOf(s))
+	if val.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: Struct needs a struct, got %T", s))
+	}
+
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+		if f.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("validate: field %s is not a string", f.Name))
+		}
+
+		field := fieldName(f)
+		value := val.Field(i).String()
+		for _, spec := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(spec, "=")
+			lookupRule(name)(v, field, value, param)
+		}
+	}
+}
+
+// lookupRule returns the rule registered as name.
+func lookupRule(name string) Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	rule, ok := rules[name]
+	if !ok {
+		panic(fmt.Sprintf("validate: unknown rule %q", name))
+	}
+	return rule
+}
+
+// fieldName returns the name of f in requests: its json name if it has one.
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+// atoi parses the length parameter of a rule.
+func atoi(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid length %q", param))
+	}
+	return n
+}
+
+// compile returns the compiled regular expression of a pattern rule.
+func compile(param string) *regexp.Regexp {
+	if re, ok := patterns.Load(param); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(param)
+	patterns.Store(param, re)
+	return re
+}
+
+// parseDate parses a date parameter.
+func parseDate(param string) time.Time {
+	t, err := time.Parse(DateLayout, param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid date %q", param))
+	}
+	return t
+}
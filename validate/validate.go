@@ -1,31 +1,184 @@
 package validate
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validation error codes.
+const (
+	CodeRequired  = "required"
+	CodeMinLength = "min_length"
+	CodeMaxLength = "max_length"
+	CodePattern   = "pattern"
+	CodeOneOf     = "one_of"
+	CodeISBN      = "isbn"
+	CodeURL       = "url"
+	CodeDate      = "date"
+	CodeDateRange = "date_range"
+)
+
+// DateLayout is the layout of dates in requests and rule parameters.
+const DateLayout = "2006-01-02"
+
+// FieldError is the validation error of a single field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Params are the limits of the rule, e.g. max for max_length, so that
+	// the message can be rebuilt in another language.
+	Params map[string]string `json:"params,omitempty"`
+}
 
 // Validator represents validation errors.
 type Validator struct {
-	errors []string
+	errors []FieldError
 }
 
 // New creates a new validator.
 func New() *Validator {
 	return &Validator{
-		errors: make([]string, 0),
+		errors: make([]FieldError, 0),
 	}
 }
 
 // Required adds an error if the value is empty.
 func (v *Validator) Required(field, value string) {
 	if value == "" {
-		v.errors = append(v.errors, fmt.Sprintf("%s is required", field))
+		v.Add(field, CodeRequired, fmt.Sprintf("%s is required", field))
 	}
 }
 
+// The rules below accept empty values, so that optional fields can be
+// checked the same way; combine them with Required otherwise.
+
+// MinLen adds an error if the value has fewer than n characters.
+func (v *Validator) MinLen(field, value string, n int) {
+	if value != "" && utf8.RuneCountInString(value) < n {
+		v.add(field, CodeMinLength, fmt.Sprintf("%s must be at least %d characters", field, n),
+			map[string]string{"min": strconv.Itoa(n)})
+	}
+}
+
+// MaxLen adds an error if the value has more than n characters.
+func (v *Validator) MaxLen(field, value string, n int) {
+	if utf8.RuneCountInString(value) > n {
+		v.add(field, CodeMaxLength, fmt.Sprintf("%s must be at most %d characters", field, n),
+			map[string]string{"max": strconv.Itoa(n)})
+	}
+}
+
+// Pattern adds an error if the value does not match re.
+func (v *Validator) Pattern(field, value string, re *regexp.Regexp) {
+	if value != "" && !re.MatchString(value) {
+		v.add(field, CodePattern, fmt.Sprintf("%s must match %s", field, re),
+			map[string]string{"pattern": re.String()})
+	}
+}
+
+// OneOf adds an error if the value is not one of allowed.
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	values := strings.Join(allowed, ", ")
+	v.add(field, CodeOneOf, fmt.Sprintf("%s must be one of %s", field, values),
+		map[string]string{"values": values})
+}
+
+// isbnPattern matches ISBN-10 and ISBN-13 once hyphens and spaces are removed.
+var isbnPattern = regexp.MustCompile(`^(\d{9}[\dX]|\d{13})$`)
+
+// ISBN adds an error if the value is not formatted as an ISBN-10 or ISBN-13.
+// Hyphens and spaces between the digits are allowed. Check digits are not
+// verified.
+func (v *Validator) ISBN(field, value string) {
+	if value != "" && !isbnPattern.MatchString(NormalizeISBN(value)) {
+		v.Add(field, CodeISBN, fmt.Sprintf("%s must be an ISBN-10 or ISBN-13", field))
+	}
+}
+
+// NormalizeISBN removes the hyphens and spaces of an ISBN.
+func NormalizeISBN(isbn string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(isbn)
+}
+
+// URL adds an error if the value is not an absolute http or https URL.
+func (v *Validator) URL(field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Add(field, CodeURL, fmt.Sprintf("%s must be an http or https URL", field))
+	}
+}
+
+// Date parses the value with layout and adds an error if it does not parse.
+// It returns the zero time for empty or invalid values.
+func (v *Validator) Date(field, value, layout string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		v.add(field, CodeDate, fmt.Sprintf("%s must be a date formatted as %s", field, layout),
+			map[string]string{"layout": layout})
+		return time.Time{}
+	}
+	return t
+}
+
+// DateBetween adds an error if the value is before min or after max. Zero
+// times are ignored.
+func (v *Validator) DateBetween(field string, value, min, max time.Time) {
+	if value.IsZero() || (!value.Before(min) && !value.After(max)) {
+		return
+	}
+	from, to := min.Format(DateLayout), max.Format(DateLayout)
+	v.add(field, CodeDateRange, fmt.Sprintf("%s must be between %s and %s", field, from, to),
+		map[string]string{"min": from, "max": to})
+}
+
+// Check adds an error with code and message if ok is false. It is the escape
+// hatch for rules that are not in this package.
+func (v *Validator) Check(ok bool, field, code, message string) {
+	if !ok {
+		v.Add(field, code, message)
+	}
+}
+
+// Add adds an error for field.
+func (v *Validator) Add(field, code, message string) {
+	v.add(field, code, message, nil)
+}
+
+// add adds an error for field with the parameters of its rule.
+func (v *Validator) add(field, code, message string, params map[string]string) {
+	v.errors = append(v.errors, FieldError{Field: field, Code: code, Message: message, Params: params})
+}
+
 // HasErrors returns true if there are validation errors.
 func (v *Validator) HasErrors() bool {
 	return len(v.errors) > 0
 }
 
+// Errors returns the validation errors in the order they were found.
+func (v *Validator) Errors() []FieldError {
+	return v.errors
+}
+
 // Error returns the validation error message.
 func (v *Validator) Error() string {
 	if len(v.errors) == 0 {
@@ -36,8 +189,7 @@ func (v *Validator) Error() string {
 		if i > 0 {
 			msg += "; "
 		}
-		msg += err
+		msg += err.Message
 	}
 	return msg
 }
-
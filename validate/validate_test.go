@@ -0,0 +1,119 @@
+package validate
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// codes returns the error codes of v by field.
+func codes(v *Validator) map[string]string {
+	result := make(map[string]string)
+	for _, err := range v.Errors() {
+		result[err.Field] = err.Code
+	}
+	return result
+}
+
+func Test_Rules(t *testing.T) {
+	Convey("Rules", t, func() {
+		v := New()
+
+		Convey("Accept valid and empty values", func() {
+			v.MinLen("title", "", 3)
+			v.MaxLen("title", "Dune", 255)
+			v.Pattern("code", "AB-12", regexp.MustCompile(`^[A-Z]{2}-\d+$`))
+			v.OneOf("format", "paperback", "hardcover", "paperback")
+			v.ISBN("isbn", "978-0-7432-7356-5")
+			v.ISBN("isbn", "080442957X")
+			v.URL("cover", "https://example.com/dune.jpg")
+			v.DateBetween("publishedAt", v.Date("publishedAt", "1965-08-01", DateLayout),
+				time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+
+			So(v.HasErrors(), ShouldBeFalse)
+		})
+
+		Convey("Report invalid values with their code", func() {
+			v.MinLen("title", "ab", 3)
+			v.MaxLen("author", strings.Repeat("é", 256), 255)
+			v.Pattern("code", "ab", regexp.MustCompile(`^[A-Z]+$`))
+			v.OneOf("format", "scroll", "hardcover", "paperback")
+			v.ISBN("isbn", "12345")
+			v.URL("cover", "ftp://example.com")
+			v.Date("publishedAt", "01-08-1965", DateLayout)
+			v.DateBetween("updatedAt", time.Date(999, 1, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+			v.Check(false, "pages", "positive", "pages must be positive")
+
+			So(codes(v), ShouldResemble, map[string]string{
+				"title":       CodeMinLength,
+				"author":      CodeMaxLength,
+				"code":        CodePattern,
+				"format":      CodeOneOf,
+				"isbn":        CodeISBN,
+				"cover":       CodeURL,
+				"publishedAt": CodeDate,
+				"updatedAt":   CodeDateRange,
+				"pages":       "positive",
+			})
+			So(v.Error(), ShouldStartWith, "validation errors: title must be at least 3 characters; author must be at most 255 characters")
+		})
+	})
+}
+
+func Test_Struct(t *testing.T) {
+	Convey("Struct", t, func() {
+		type request struct {
+			Title       string `json:"title" validate:"required,max=5"`
+			Format      string `json:"format,omitempty" validate:"oneof=hardcover paperback"`
+			Code        string `validate:"pattern=^[A-Z]+$"`
+			PublishedAt string `json:"publishedAt" validate:"date,datebetween=1000-01-01 9999-12-31"`
+			Notes       string `json:"notes"`
+		}
+
+		Convey("Name fields after their json tag", func() {
+			v := New()
+			v.Struct(&request{Format: "scroll", Code: "ab", PublishedAt: "0999-01-01"})
+
+			So(v.Errors(), ShouldResemble, []FieldError{
+				{Field: "title", Code: CodeRequired, Message: "title is required"},
+				{Field: "format", Code: CodeOneOf, Message: "format must be one of hardcover, paperback",
+					Params: map[string]string{"values": "hardcover, paperback"}},
+				{Field: "Code", Code: CodePattern, Message: "Code must match ^[A-Z]+$",
+					Params: map[string]string{"pattern": "^[A-Z]+$"}},
+				{Field: "publishedAt", Code: CodeDateRange, Message: "publishedAt must be between 1000-01-01 and 9999-12-31",
+					Params: map[string]string{"min": "1000-01-01", "max": "9999-12-31"}},
+			})
+		})
+
+		Convey("Report a malformed date once", func() {
+			v := New()
+			v.Struct(request{Title: "Dune", PublishedAt: "yesterday"})
+
+			So(codes(v), ShouldResemble, map[string]string{"publishedAt": CodeDate})
+		})
+
+		Convey("Use registered rules", func() {
+			Register("upper", func(v *Validator, field, value, _ string) {
+				v.Check(strings.ToUpper(value) == value, field, "upper", field+" must be upper case")
+			})
+			type tagged struct {
+				Shelf string `json:"shelf" validate:"upper"`
+			}
+
+			v := New()
+			v.Struct(tagged{Shelf: "a1"})
+			So(codes(v), ShouldResemble, map[string]string{"shelf": "upper"})
+		})
+
+		Convey("Panic on unknown rules", func() {
+			type tagged struct {
+				Shelf string `validate:"shelf"`
+			}
+			So(func() { New().Struct(tagged{}) }, ShouldPanic)
+		})
+	})
+}